@@ -0,0 +1,1070 @@
+// Package asm assembles CHIP-8, SUPER-CHIP and XO-CHIP programs written in
+// the Octo assembly language.
+//
+// The assembler supports Octo's instructions, labels, :const, :alias,
+// :macro, :calc, :byte, :org, :call and :unpack, and the if-then,
+// if-begin-else-end and loop-while-again control structures, including the
+// <, >, <= and >= comparisons, which use VF as a temporary. Tokens must be
+// separated by whitespace.
+//
+// Execution starts at the beginning of the program. As in Octo, a program
+// that does not start with the label main starts with a jump to main.
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// maxMacroDepth limits how deeply macro expansions nest, which stops a macro
+// that invokes itself from expanding forever.
+const maxMacroDepth = 1000
+
+// Error describes a problem with the source, and where it is.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Program holds an assembled program.
+type Program struct {
+	// Origin holds the address the ROM is loaded at.
+	Origin uint16
+
+	// ROM holds the assembled program.
+	ROM []byte
+
+	// Labels holds the address of each label.
+	Labels map[string]uint16
+
+	// Consts holds the value of each constant defined by :const or :calc.
+	Consts map[string]int
+}
+
+// WriteSymbols writes the symbol table of the program to w: one line for
+// each label, in address order, giving its address, followed by one line for
+// each constant, in name order, giving its value.
+func (p *Program) WriteSymbols(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	labels := make([]string, 0, len(p.Labels))
+	for name := range p.Labels {
+		labels = append(labels, name)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := p.Labels[labels[i]], p.Labels[labels[j]]
+		return a < b || a == b && labels[i] < labels[j]
+	})
+	for _, name := range labels {
+		fmt.Fprintf(b, "label %s 0x%04X\n", name, p.Labels[name])
+	}
+	consts := make([]string, 0, len(p.Consts))
+	for name := range p.Consts {
+		consts = append(consts, name)
+	}
+	sort.Strings(consts)
+	for _, name := range consts {
+		fmt.Fprintf(b, "const %s %d\n", name, p.Consts[name])
+	}
+	return b.Flush()
+}
+
+// macro holds a macro defined by :macro.
+type macro struct {
+	params []string
+	body   []token
+}
+
+// fixupKind identifies how a forward reference to a label is filled in.
+type fixupKind int
+
+const (
+	fixAddr   fixupKind = iota // the low 12 bits of an instruction
+	fixLong                    // the 16-bit word after F000
+	fixUnpack                  // the two bytes loaded by :unpack
+)
+
+// fixup records a reference to a label that was not yet defined.
+type fixup struct {
+	kind  fixupKind
+	addr  int
+	label string
+	line  int
+}
+
+// block records an open if-begin or loop.
+type block struct {
+	loop   bool
+	line   int
+	start  int   // the address of the start of a loop
+	jumps  []int // the jumps to patch with the address of the end
+	inElse bool
+}
+
+// assembler holds the state of one assembly.
+type assembler struct {
+	file  string
+	toks  []token
+	pos   int
+	line  int
+	mem   [emulator.XOCHIPMemorySize]byte
+	here  int
+	start int
+	max   int
+
+	labels  map[string]int
+	consts  map[string]int
+	aliases map[string]byte
+	macros  map[string]*macro
+	fixups  []fixup
+	blocks  []block
+
+	started   bool
+	jumpMain  bool
+	firstLine int
+}
+
+// Assemble assembles src, which is named file in error messages.
+func Assemble(file string, src []byte) (*Program, error) {
+	a := &assembler{
+		file:    file,
+		toks:    tokenize(string(src)),
+		here:    emulator.ProgramAddress,
+		start:   emulator.ProgramAddress,
+		max:     emulator.ProgramAddress,
+		labels:  make(map[string]int),
+		consts:  make(map[string]int),
+		aliases: make(map[string]byte),
+		macros:  make(map[string]*macro),
+	}
+	for a.pos < len(a.toks) {
+		if err := a.statement(); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.finish(); err != nil {
+		return nil, err
+	}
+	p := &Program{
+		Origin: uint16(a.start),
+		ROM:    append([]byte(nil), a.mem[a.start:a.max]...),
+		Labels: make(map[string]uint16, len(a.labels)),
+		Consts: a.consts,
+	}
+	for name, addr := range a.labels {
+		p.Labels[name] = uint16(addr)
+	}
+	return p, nil
+}
+
+func (a *assembler) errorf(line int, format string, args ...interface{}) error {
+	return &Error{File: a.file, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns the next token.
+func (a *assembler) next() (token, error) {
+	if a.pos >= len(a.toks) {
+		return token{}, a.errorf(a.line, "unexpected end of file")
+	}
+	t := a.toks[a.pos]
+	a.pos++
+	a.line = t.line
+	return t, nil
+}
+
+// peek returns the text of the next token, or "" at the end of the file.
+func (a *assembler) peek() string {
+	if a.pos >= len(a.toks) {
+		return ""
+	}
+	return a.toks[a.pos].text
+}
+
+// expect consumes the next token, which must be text.
+func (a *assembler) expect(text string) error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if t.text != text {
+		return a.errorf(t.line, "expected %q, got %q", text, t.text)
+	}
+	return nil
+}
+
+// name returns the next token as the name of a new symbol.
+func (a *assembler) name() (string, error) {
+	t, err := a.next()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := parseNumber(t.text); ok || strings.HasPrefix(t.text, ":") || t.text == "{" || t.text == "}" {
+		return "", a.errorf(t.line, "invalid name %q", t.text)
+	}
+	if _, ok := parseRegister(t.text); ok {
+		return "", a.errorf(t.line, "invalid name %q", t.text)
+	}
+	return t.text, nil
+}
+
+// lookup returns the value of a number, constant or defined label.
+func (a *assembler) lookup(s string) (int, bool) {
+	if n, ok := parseNumber(s); ok {
+		return n, true
+	}
+	if v, ok := a.consts[s]; ok {
+		return v, true
+	}
+	v, ok := a.labels[s]
+	return v, ok
+}
+
+// register returns the next token as a register number.
+func (a *assembler) register() (byte, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	if r, ok := a.aliases[t.text]; ok {
+		return r, nil
+	}
+	if r, ok := parseRegister(t.text); ok {
+		return r, nil
+	}
+	return 0, a.errorf(t.line, "expected a register, got %q", t.text)
+}
+
+// isRegister reports whether s names a register.
+func (a *assembler) isRegister(s string) bool {
+	if _, ok := a.aliases[s]; ok {
+		return true
+	}
+	_, ok := parseRegister(s)
+	return ok
+}
+
+// braces returns the tokens up to the } matching a { that has just been
+// read.
+func (a *assembler) braces() ([]token, error) {
+	open := a.line
+	depth := 1
+	begin := a.pos
+	for ; a.pos < len(a.toks); a.pos++ {
+		switch a.toks[a.pos].text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+		if depth == 0 {
+			body := a.toks[begin:a.pos]
+			a.pos++
+			return body, nil
+		}
+	}
+	return nil, a.errorf(open, "missing }")
+}
+
+// value returns the value of the next token, which is a number, constant,
+// defined label or { expression }.
+func (a *assembler) value() (int, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	if t.text == "{" {
+		toks, err := a.braces()
+		if err != nil {
+			return 0, err
+		}
+		return a.calc(toks, t.line)
+	}
+	v, ok := a.lookup(t.text)
+	if !ok {
+		return 0, a.errorf(t.line, "undefined name %q", t.text)
+	}
+	return v, nil
+}
+
+// byteValue returns the next value, which must fit in a byte.
+func (a *assembler) byteValue() (byte, error) {
+	v, err := a.value()
+	if err != nil {
+		return 0, err
+	}
+	if v < -128 || v > 255 {
+		return 0, a.errorf(a.line, "value %d does not fit in a byte", v)
+	}
+	return byte(v), nil
+}
+
+// nibble returns the next value, which must fit in 4 bits.
+func (a *assembler) nibble() (byte, error) {
+	v, err := a.value()
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > 15 {
+		return 0, a.errorf(a.line, "value %d does not fit in 4 bits", v)
+	}
+	return byte(v), nil
+}
+
+// begin notes that the program has started, either with a label or with an
+// instruction or data, and reserves room for the jump to main if the program
+// does not start with main.
+func (a *assembler) begin(label string) {
+	if a.started {
+		return
+	}
+	a.started = true
+	a.firstLine = a.line
+	if label != "main" {
+		a.jumpMain = true
+		a.here += 2
+		a.max = a.here
+	}
+}
+
+// emit appends bytes to the program.
+func (a *assembler) emit(b ...byte) error {
+	a.begin("")
+	for _, c := range b {
+		if a.here >= len(a.mem) {
+			return a.errorf(a.line, "program does not fit in memory")
+		}
+		a.mem[a.here] = c
+		a.here++
+	}
+	if a.here > a.max {
+		a.max = a.here
+	}
+	return nil
+}
+
+// op appends an instruction to the program.
+func (a *assembler) op(opcode uint16) error {
+	return a.emit(byte(opcode>>8), byte(opcode))
+}
+
+// opAddr appends an instruction whose low 12 bits are the address given by
+// the next token, which may name a label defined later.
+func (a *assembler) opAddr(opcode uint16) error {
+	if a.forward(fixAddr) {
+		return a.op(opcode)
+	}
+	v, err := a.value()
+	if err != nil {
+		return err
+	}
+	if v < 0 || v > 0xFFF {
+		return a.errorf(a.line, "address 0x%X out of range", v)
+	}
+	return a.op(opcode | uint16(v))
+}
+
+// forward consumes the next token and records a fixup of the given kind for
+// it at the current address if it names a label that is not yet defined, and
+// reports whether it did.
+func (a *assembler) forward(kind fixupKind) bool {
+	if a.pos >= len(a.toks) {
+		return false
+	}
+	t := a.toks[a.pos]
+	if _, ok := a.lookup(t.text); ok || t.text == "{" {
+		return false
+	}
+	a.pos++
+	a.line = t.line
+	// Reserve the jump to main first, which moves the current address.
+	a.begin("")
+	a.fixups = append(a.fixups, fixup{kind, a.here, t.text, t.line})
+	return true
+}
+
+// statement assembles one statement.
+func (a *assembler) statement() error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	switch t.text {
+	case ":":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		return a.define(name)
+	case ":const":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		return a.defineConst(name, v)
+	case ":calc":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		if err := a.expect("{"); err != nil {
+			return err
+		}
+		line := a.line
+		toks, err := a.braces()
+		if err != nil {
+			return err
+		}
+		v, err := a.calc(toks, line)
+		if err != nil {
+			return err
+		}
+		return a.defineConst(name, v)
+	case ":alias":
+		name, err := a.name()
+		if err != nil {
+			return err
+		}
+		r, err := a.register()
+		if err != nil {
+			return err
+		}
+		a.aliases[name] = r
+		return nil
+	case ":macro":
+		return a.defineMacro()
+	case ":byte":
+		b, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.emit(b)
+	case ":org":
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		if v < 0 || v >= len(a.mem) {
+			return a.errorf(t.line, "address 0x%X out of range", v)
+		}
+		if !a.started {
+			// The program starts wherever it is placed.
+			a.started = true
+			a.here, a.start, a.max = v, v, v
+			return nil
+		}
+		if v < a.start {
+			return a.errorf(t.line, "address 0x%X is before the start of the program", v)
+		}
+		a.here = v
+		return nil
+	case ":call":
+		return a.opAddr(0x2000)
+	case ":unpack":
+		return a.unpack()
+	case ":breakpoint":
+		// Breakpoints are for Octo's debugger and assemble to nothing.
+		_, err := a.next()
+		return err
+	case "clear":
+		return a.op(0x00E0)
+	case "return":
+		return a.op(0x00EE)
+	case "scroll-down":
+		n, err := a.nibble()
+		if err != nil {
+			return err
+		}
+		return a.op(0x00C0 | uint16(n))
+	case "scroll-up":
+		n, err := a.nibble()
+		if err != nil {
+			return err
+		}
+		return a.op(0x00D0 | uint16(n))
+	case "scroll-right":
+		return a.op(0x00FB)
+	case "scroll-left":
+		return a.op(0x00FC)
+	case "exit":
+		return a.op(0x00FD)
+	case "lores":
+		return a.op(0x00FE)
+	case "hires":
+		return a.op(0x00FF)
+	case "native":
+		return a.opAddr(0x0000)
+	case "jump":
+		return a.opAddr(0x1000)
+	case "jump0":
+		return a.opAddr(0xB000)
+	case "sprite":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		y, err := a.register()
+		if err != nil {
+			return err
+		}
+		n, err := a.nibble()
+		if err != nil {
+			return err
+		}
+		return a.op(0xD000 | uint16(x)<<8 | uint16(y)<<4 | uint16(n))
+	case "plane":
+		n, err := a.nibble()
+		if err != nil {
+			return err
+		}
+		if n > 3 {
+			return a.errorf(t.line, "plane %d out of range", n)
+		}
+		return a.op(0xF001 | uint16(n)<<8)
+	case "audio":
+		return a.op(0xF002)
+	case "bcd":
+		return a.opX(0xF033)
+	case "save", "load":
+		x, err := a.register()
+		if err != nil {
+			return err
+		}
+		if a.peek() == "-" {
+			a.pos++
+			y, err := a.register()
+			if err != nil {
+				return err
+			}
+			op := uint16(0x5002)
+			if t.text == "load" {
+				op = 0x5003
+			}
+			return a.op(op | uint16(x)<<8 | uint16(y)<<4)
+		}
+		op := uint16(0xF055)
+		if t.text == "load" {
+			op = 0xF065
+		}
+		return a.op(op | uint16(x)<<8)
+	case "saveflags":
+		return a.opX(0xF075)
+	case "loadflags":
+		return a.opX(0xF085)
+	case "delay", "buzzer", "pitch":
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+		ops := map[string]uint16{"delay": 0xF015, "buzzer": 0xF018, "pitch": 0xF03A}
+		return a.opX(ops[t.text])
+	case "i":
+		return a.assignI()
+	case "if":
+		return a.conditional()
+	case "else":
+		return a.elseBlock()
+	case "end":
+		return a.endBlock()
+	case "loop":
+		a.begin("")
+		a.blocks = append(a.blocks, block{loop: true, line: t.line, start: a.here})
+		return nil
+	case "while":
+		return a.while()
+	case "again":
+		return a.again()
+	}
+	if m, ok := a.macros[t.text]; ok {
+		return a.expand(t, m)
+	}
+	if a.isRegister(t.text) {
+		a.pos--
+		return a.assign()
+	}
+	if n, ok := parseNumber(t.text); ok {
+		if n < -128 || n > 255 {
+			return a.errorf(t.line, "value %d does not fit in a byte", n)
+		}
+		return a.emit(byte(n))
+	}
+	if strings.HasPrefix(t.text, ":") || t.text == "{" || t.text == "}" {
+		return a.errorf(t.line, "unexpected %q", t.text)
+	}
+	// Anything else calls the subroutine it names.
+	a.pos--
+	return a.opAddr(0x2000)
+}
+
+// opX appends an instruction taking the register given by the next token.
+func (a *assembler) opX(opcode uint16) error {
+	x, err := a.register()
+	if err != nil {
+		return err
+	}
+	return a.op(opcode | uint16(x)<<8)
+}
+
+// define defines a label at the current address.
+func (a *assembler) define(name string) error {
+	a.begin(name)
+	if _, ok := a.labels[name]; ok {
+		return a.errorf(a.line, "label %q already defined", name)
+	}
+	if _, ok := a.consts[name]; ok {
+		return a.errorf(a.line, "label %q already defined as a constant", name)
+	}
+	a.labels[name] = a.here
+	return nil
+}
+
+func (a *assembler) defineConst(name string, v int) error {
+	if _, ok := a.labels[name]; ok {
+		return a.errorf(a.line, "constant %q already defined as a label", name)
+	}
+	a.consts[name] = v
+	return nil
+}
+
+// defineMacro reads a macro definition: its name, parameters and a body in
+// braces.
+func (a *assembler) defineMacro() error {
+	name, err := a.name()
+	if err != nil {
+		return err
+	}
+	m := &macro{}
+	for {
+		t, err := a.next()
+		if err != nil {
+			return err
+		}
+		if t.text == "{" {
+			break
+		}
+		m.params = append(m.params, t.text)
+	}
+	body, err := a.braces()
+	if err != nil {
+		return err
+	}
+	// The tokens read are overwritten by expansions, so keep a copy.
+	m.body = append([]token(nil), body...)
+	a.macros[name] = m
+	return nil
+}
+
+// expand replaces the invocation call of a macro with the body of the macro,
+// with its parameters replaced by the arguments that follow the invocation.
+// Arguments keep their own lines, so errors in them point at the invocation.
+func (a *assembler) expand(call token, m *macro) error {
+	if call.depth >= maxMacroDepth {
+		return a.errorf(call.line, "macro expansions nested too deeply")
+	}
+	args := make(map[string]token, len(m.params))
+	for _, p := range m.params {
+		t, err := a.next()
+		if err != nil {
+			return err
+		}
+		args[p] = t
+	}
+	a.reserve(len(m.body))
+	a.pos -= len(m.body)
+	for k, t := range m.body {
+		if arg, ok := args[t.text]; ok {
+			t = arg
+		}
+		t.depth = call.depth + 1
+		a.toks[a.pos+k] = t
+	}
+	return nil
+}
+
+// reserve makes room for n tokens before the next one. The tokens already
+// read are no longer needed and are overwritten; if there are fewer than n,
+// the rest of the tokens are moved up, leaving room for as many again so
+// that expansion takes linear time overall.
+func (a *assembler) reserve(n int) {
+	if n <= a.pos {
+		return
+	}
+	rest := a.toks[a.pos:]
+	room := n + len(rest)
+	toks := make([]token, room+len(rest))
+	copy(toks[room:], rest)
+	a.toks = toks
+	a.pos = room
+}
+
+// unpack assembles :unpack n label, which loads v0 with n in its high nibble
+// and the high nibble of the address of label, and v1 with the low byte of
+// the address.
+func (a *assembler) unpack() error {
+	n, err := a.nibble()
+	if err != nil {
+		return err
+	}
+	if a.forward(fixUnpack) {
+		return a.emit(0x60, n<<4, 0x61, 0x00)
+	}
+	v, err := a.value()
+	if err != nil {
+		return err
+	}
+	return a.emit(0x60, n<<4|byte(v>>8)&0x0F, 0x61, byte(v))
+}
+
+// assignI assembles the assignments to I.
+func (a *assembler) assignI() error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	switch t.text {
+	case "+=":
+		return a.opX(0xF01E)
+	case ":=":
+	default:
+		return a.errorf(t.line, "expected := or += after i, got %q", t.text)
+	}
+	switch a.peek() {
+	case "hex":
+		a.pos++
+		return a.opX(0xF029)
+	case "bighex":
+		a.pos++
+		return a.opX(0xF030)
+	case "long":
+		a.pos++
+		if err := a.op(0xF000); err != nil {
+			return err
+		}
+		if a.forward(fixLong) {
+			return a.emit(0, 0)
+		}
+		v, err := a.value()
+		if err != nil {
+			return err
+		}
+		if v < 0 || v > 0xFFFF {
+			return a.errorf(a.line, "address 0x%X out of range", v)
+		}
+		return a.emit(byte(v>>8), byte(v))
+	}
+	return a.opAddr(0xA000)
+}
+
+// assign assembles the assignments to a register.
+func (a *assembler) assign() error {
+	x, err := a.register()
+	if err != nil {
+		return err
+	}
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+	vx := uint16(x) << 8
+	regOps := map[string]uint16{
+		":=": 0x8000, "|=": 0x8001, "&=": 0x8002, "^=": 0x8003, "+=": 0x8004,
+		"-=": 0x8005, ">>=": 0x8006, "=-": 0x8007, "<<=": 0x800E,
+	}
+	if code, ok := regOps[opTok.text]; ok && a.isRegister(a.peek()) {
+		y, _ := a.register()
+		return a.op(code | vx | uint16(y)<<4)
+	}
+	switch opTok.text {
+	case ":=":
+		switch a.peek() {
+		case "random":
+			a.pos++
+			kk, err := a.byteValue()
+			if err != nil {
+				return err
+			}
+			return a.op(0xC000 | vx | uint16(kk))
+		case "delay":
+			a.pos++
+			return a.op(0xF007 | vx)
+		case "key":
+			a.pos++
+			return a.op(0xF00A | vx)
+		}
+		kk, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.op(0x6000 | vx | uint16(kk))
+	case "+=", "-=":
+		kk, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		if opTok.text == "-=" {
+			kk = -kk
+		}
+		return a.op(0x7000 | vx | uint16(kk))
+	}
+	if _, ok := regOps[opTok.text]; ok {
+		return a.errorf(opTok.line, "%s needs a register operand", opTok.text)
+	}
+	return a.errorf(opTok.line, "unknown operator %q", opTok.text)
+}
+
+// condition holds a compiled condition: the instructions that prepare it and
+// the skips taken when it is false and when it is true.
+type condition struct {
+	prepare   []uint16
+	skipFalse uint16
+	skipTrue  uint16
+}
+
+// condition reads a condition: a register, a comparison and a register or
+// byte, or a register followed by key or -key.
+func (a *assembler) condition() (condition, error) {
+	x, err := a.register()
+	if err != nil {
+		return condition{}, err
+	}
+	vx := uint16(x) << 8
+	cmp, err := a.next()
+	if err != nil {
+		return condition{}, err
+	}
+	switch cmp.text {
+	case "key":
+		return condition{skipFalse: 0xE0A1 | vx, skipTrue: 0xE09E | vx}, nil
+	case "-key":
+		return condition{skipFalse: 0xE09E | vx, skipTrue: 0xE0A1 | vx}, nil
+	case "==", "!=", "<", ">", "<=", ">=":
+	default:
+		return condition{}, a.errorf(cmp.line, "unknown comparison %q", cmp.text)
+	}
+
+	var y byte
+	var kk byte
+	isReg := a.isRegister(a.peek())
+	if isReg {
+		y, _ = a.register()
+	} else if kk, err = a.byteValue(); err != nil {
+		return condition{}, err
+	}
+	switch cmp.text {
+	case "==", "!=":
+		eq, ne := 0x3000|vx|uint16(kk), 0x4000|vx|uint16(kk)
+		if isReg {
+			eq, ne = 0x5000|vx|uint16(y)<<4, 0x9000|vx|uint16(y)<<4
+		}
+		if cmp.text == "==" {
+			return condition{skipFalse: ne, skipTrue: eq}, nil
+		}
+		return condition{skipFalse: eq, skipTrue: ne}, nil
+	}
+
+	// Compute a subtraction in VF, whose flag is the result of the
+	// comparison or its opposite.
+	if x == 0xF || isReg && y == 0xF {
+		return condition{}, a.errorf(cmp.line, "vf cannot be used with %s", cmp.text)
+	}
+	var c condition
+	if isReg {
+		if cmp.text == "<" || cmp.text == ">=" {
+			// VF = vx - vy; the flag is set if vx >= vy.
+			c.prepare = []uint16{0x8F00 | uint16(x)<<4, 0x8F05 | uint16(y)<<4}
+		} else {
+			// VF = vy - vx; the flag is set if vx <= vy.
+			c.prepare = []uint16{0x8F00 | uint16(y)<<4, 0x8F05 | uint16(x)<<4}
+		}
+	} else {
+		if cmp.text == "<" || cmp.text == ">=" {
+			// VF = vx - kk; the flag is set if vx >= kk.
+			c.prepare = []uint16{0x6F00 | uint16(kk), 0x8F07 | uint16(x)<<4}
+		} else {
+			// VF = kk - vx; the flag is set if vx <= kk.
+			c.prepare = []uint16{0x6F00 | uint16(kk), 0x8F05 | uint16(x)<<4}
+		}
+	}
+	flagClear, flagSet := uint16(0x3F00), uint16(0x4F00)
+	if cmp.text == "<" || cmp.text == ">" {
+		// The flag is set when the condition is false.
+		c.skipFalse, c.skipTrue = flagSet, flagClear
+	} else {
+		c.skipFalse, c.skipTrue = flagClear, flagSet
+	}
+	return c, nil
+}
+
+// prepare appends the instructions that prepare a condition.
+func (a *assembler) prepare(c condition) error {
+	for _, op := range c.prepare {
+		if err := a.op(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conditional assembles if ... then, which skips the next statement if the
+// condition is false, and if ... begin, which opens a block.
+func (a *assembler) conditional() error {
+	line := a.line
+	c, err := a.condition()
+	if err != nil {
+		return err
+	}
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if err := a.prepare(c); err != nil {
+		return err
+	}
+	switch t.text {
+	case "then":
+		return a.op(c.skipFalse)
+	case "begin":
+		if err := a.op(c.skipTrue); err != nil {
+			return err
+		}
+		a.blocks = append(a.blocks, block{line: line, jumps: []int{a.here}})
+		return a.op(0x1000)
+	}
+	return a.errorf(t.line, "expected then or begin, got %q", t.text)
+}
+
+// elseBlock assembles the else of an if-begin block.
+func (a *assembler) elseBlock() error {
+	k := len(a.blocks) - 1
+	if k < 0 || a.blocks[k].loop || a.blocks[k].inElse {
+		return a.errorf(a.line, "else without if ... begin")
+	}
+	b := &a.blocks[k]
+	jump := a.here
+	if err := a.op(0x1000); err != nil {
+		return err
+	}
+	if err := a.patchJumps(b.jumps, a.here); err != nil {
+		return err
+	}
+	b.jumps = []int{jump}
+	b.inElse = true
+	return nil
+}
+
+// endBlock assembles the end of an if-begin block.
+func (a *assembler) endBlock() error {
+	k := len(a.blocks) - 1
+	if k < 0 || a.blocks[k].loop {
+		return a.errorf(a.line, "end without if ... begin")
+	}
+	b := a.blocks[k]
+	a.blocks = a.blocks[:k]
+	return a.patchJumps(b.jumps, a.here)
+}
+
+// while assembles a loop exit taken when the condition is false.
+func (a *assembler) while() error {
+	k := len(a.blocks) - 1
+	for ; k >= 0 && !a.blocks[k].loop; k-- {
+	}
+	if k < 0 {
+		return a.errorf(a.line, "while outside a loop")
+	}
+	c, err := a.condition()
+	if err != nil {
+		return err
+	}
+	if err := a.prepare(c); err != nil {
+		return err
+	}
+	if err := a.op(c.skipTrue); err != nil {
+		return err
+	}
+	a.blocks[k].jumps = append(a.blocks[k].jumps, a.here)
+	return a.op(0x1000)
+}
+
+// again assembles the end of a loop.
+func (a *assembler) again() error {
+	k := len(a.blocks) - 1
+	if k < 0 || !a.blocks[k].loop {
+		return a.errorf(a.line, "again without loop")
+	}
+	b := a.blocks[k]
+	a.blocks = a.blocks[:k]
+	if b.start > 0xFFF {
+		return a.errorf(a.line, "address 0x%X out of range", b.start)
+	}
+	if err := a.op(0x1000 | uint16(b.start)); err != nil {
+		return err
+	}
+	return a.patchJumps(b.jumps, a.here)
+}
+
+// patchJumps sets the target of the jump instructions at addrs.
+func (a *assembler) patchJumps(addrs []int, target int) error {
+	if target > 0xFFF {
+		return a.errorf(a.line, "address 0x%X out of range", target)
+	}
+	for _, addr := range addrs {
+		a.mem[addr] = a.mem[addr]&0xF0 | byte(target>>8)
+		a.mem[addr+1] = byte(target)
+	}
+	return nil
+}
+
+// finish checks that every block is closed and fills in the references to
+// labels defined after their use.
+func (a *assembler) finish() error {
+	if len(a.blocks) > 0 {
+		b := a.blocks[len(a.blocks)-1]
+		if b.loop {
+			return a.errorf(b.line, "loop without again")
+		}
+		return a.errorf(b.line, "if ... begin without end")
+	}
+	if a.jumpMain {
+		main, ok := a.labels["main"]
+		if !ok {
+			return a.errorf(a.firstLine, "program does not start with main and main is not defined")
+		}
+		if main > 0xFFF {
+			return a.errorf(a.firstLine, "address 0x%X out of range", main)
+		}
+		a.mem[a.start] = 0x10 | byte(main>>8)
+		a.mem[a.start+1] = byte(main)
+	}
+	for _, f := range a.fixups {
+		v, ok := a.labels[f.label]
+		if !ok {
+			return a.errorf(f.line, "undefined label %q", f.label)
+		}
+		switch f.kind {
+		case fixAddr:
+			if v > 0xFFF {
+				return a.errorf(f.line, "address 0x%X out of range", v)
+			}
+			a.mem[f.addr] |= byte(v >> 8)
+			a.mem[f.addr+1] = byte(v)
+		case fixLong:
+			a.mem[f.addr] = byte(v >> 8)
+			a.mem[f.addr+1] = byte(v)
+		case fixUnpack:
+			a.mem[f.addr+1] |= byte(v>>8) & 0x0F
+			a.mem[f.addr+3] = byte(v)
+		}
+	}
+	return nil
+}
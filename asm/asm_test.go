@@ -0,0 +1,332 @@
+package asm
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/markcol/chip8-go/disasm"
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// assemble assembles src, which must start with main, and fails the test on
+// error.
+func assemble(t *testing.T, src string) *Program {
+	t.Helper()
+	p, err := Assemble("test.8o", []byte(src))
+	if err != nil {
+		t.Fatalf("Assemble() = %v, expected nil", err)
+	}
+	return p
+}
+
+func TestInstructions(t *testing.T) {
+	tests := []struct {
+		src string
+		exp []byte
+	}{
+		{"clear return exit", []byte{0x00, 0xE0, 0x00, 0xEE, 0x00, 0xFD}},
+		{"scroll-down 3 scroll-up 0xA", []byte{0x00, 0xC3, 0x00, 0xDA}},
+		{"scroll-left scroll-right lores hires", []byte{0x00, 0xFC, 0x00, 0xFB, 0x00, 0xFE, 0x00, 0xFF}},
+		{"native 0x123 jump 0x345 jump0 0x300 :call 0x456", []byte{0x01, 0x23, 0x13, 0x45, 0xB3, 0x00, 0x24, 0x56}},
+		{"v3 := 0x12 v3 += 1 v3 -= 1", []byte{0x63, 0x12, 0x73, 0x01, 0x73, 0xFF}},
+		{"va := vb va |= vb va &= vb va ^= vb", []byte{0x8A, 0xB0, 0x8A, 0xB1, 0x8A, 0xB2, 0x8A, 0xB3}},
+		{"va += vb va -= vb va >>= vb va =- vb va <<= vb", []byte{0x8A, 0xB4, 0x8A, 0xB5, 0x8A, 0xB6, 0x8A, 0xB7, 0x8A, 0xBE}},
+		{"v1 := random 0x0F v1 := delay v1 := key", []byte{0xC1, 0x0F, 0xF1, 0x07, 0xF1, 0x0A}},
+		{"delay := v2 buzzer := v2 pitch := v2", []byte{0xF2, 0x15, 0xF2, 0x18, 0xF2, 0x3A}},
+		{"i := 0x234 i += v1 i := hex v1 i := bighex v1", []byte{0xA2, 0x34, 0xF1, 0x1E, 0xF1, 0x29, 0xF1, 0x30}},
+		{"i := long 0x1234 audio plane 3", []byte{0xF0, 0x00, 0x12, 0x34, 0xF0, 0x02, 0xF3, 0x01}},
+		{"sprite v1 v2 15 bcd v3", []byte{0xD1, 0x2F, 0xF3, 0x33}},
+		{"save v4 load v4 save v1 - v3 load v3 - v1", []byte{0xF4, 0x55, 0xF4, 0x65, 0x51, 0x32, 0x53, 0x13}},
+		{"saveflags v7 loadflags v7", []byte{0xF7, 0x75, 0xF7, 0x85}},
+		{"if v1 == 5 then if v1 != v2 then", []byte{0x41, 0x05, 0x51, 0x20}},
+		{"if v1 key then if v1 -key then", []byte{0xE1, 0xA1, 0xE1, 0x9E}},
+		{"1 0x20 0b101 -1 :byte { 2 * 3 }", []byte{0x01, 0x20, 0x05, 0xFF, 0x06}},
+	}
+	for _, tt := range tests {
+		p, err := Assemble("test.8o", []byte(": main "+tt.src))
+		if err != nil {
+			t.Errorf("Assemble(%q) = %v, expected nil", tt.src, err)
+			continue
+		}
+		if !bytes.Equal(p.ROM, tt.exp) {
+			t.Errorf("Assemble(%q) = % X, expected % X", tt.src, p.ROM, tt.exp)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	p := assemble(t, `
+: main
+	i := sprite   # a forward reference
+	draw
+	jump main
+: draw
+	sprite v0 v0 2
+	return
+: sprite
+	0xF0 0x90
+`)
+	exp := []byte{0xA2, 0x0A, 0x22, 0x06, 0x12, 0x00, 0xD0, 0x02, 0x00, 0xEE, 0xF0, 0x90}
+	if !bytes.Equal(p.ROM, exp) {
+		t.Errorf("ROM = % X, expected % X", p.ROM, exp)
+	}
+	if p.Labels["draw"] != 0x206 || p.Labels["sprite"] != 0x20A {
+		t.Errorf("Labels = %v, expected draw at 206 and sprite at 20A", p.Labels)
+	}
+}
+
+// Test that a program that does not start with main starts with a jump to
+// main.
+func TestJumpToMain(t *testing.T) {
+	p := assemble(t, `
+: data 1 2
+: main
+	jump main
+`)
+	exp := []byte{0x12, 0x04, 0x01, 0x02, 0x12, 0x04}
+	if !bytes.Equal(p.ROM, exp) {
+		t.Errorf("ROM = % X, expected % X", p.ROM, exp)
+	}
+
+	p = assemble(t, ":org 0x600 : start jump start")
+	if p.Origin != 0x600 || !bytes.Equal(p.ROM, []byte{0x16, 0x00}) {
+		t.Errorf("program at %03X = % X, expected 16 00 at 600", p.Origin, p.ROM)
+	}
+
+	// A forward jump as the first instruction follows the jump to main.
+	p = assemble(t, "jump next : next : main clear")
+	exp = []byte{0x12, 0x04, 0x12, 0x04, 0x00, 0xE0}
+	if !bytes.Equal(p.ROM, exp) {
+		t.Errorf("ROM = % X, expected % X", p.ROM, exp)
+	}
+}
+
+// Test that the number of macro expansions is not limited, only how deeply
+// they nest.
+func TestManyMacroExpansions(t *testing.T) {
+	var src strings.Builder
+	src.WriteString(":macro inc r { r += 1 }\n:macro inc2 r { inc r inc r }\n: main\n")
+	for k := 0; k < 10000; k++ {
+		src.WriteString("inc2 v0\n")
+	}
+	p := assemble(t, src.String())
+	if len(p.ROM) != 40000 || p.ROM[0] != 0x70 || p.ROM[39999] != 0x01 {
+		t.Errorf("ROM has %d bytes, expected 40000 bytes of ADD V0,1", len(p.ROM))
+	}
+}
+
+func TestMetaprogramming(t *testing.T) {
+	p := assemble(t, `
+:const SPEED 3
+:alias x v4
+:calc DOUBLE { SPEED * 2 + 1 }
+:macro set reg value { reg := value }
+: main
+	set x SPEED
+	set v5 DOUBLE
+	:unpack 0xA table
+	:byte { HERE & 0xFF }
+: table
+`)
+	// Operators are evaluated right to left: 3 * (2 + 1) = 9.
+	exp := []byte{0x64, 0x03, 0x65, 0x09, 0x60, 0xA2, 0x61, 0x09, 0x08}
+	if !bytes.Equal(p.ROM, exp) {
+		t.Errorf("ROM = % X, expected % X", p.ROM, exp)
+	}
+	if p.Consts["DOUBLE"] != 9 || p.Consts["SPEED"] != 3 {
+		t.Errorf("Consts = %v, expected SPEED 3 and DOUBLE 9", p.Consts)
+	}
+}
+
+func TestCalc(t *testing.T) {
+	tests := []struct {
+		expr string
+		exp  int
+	}{
+		{"1 + 2", 3},
+		{"10 - 2 - 3", 11},
+		{"( 10 - 2 ) - 3", 5},
+		{"- 3 + 5", 2},
+		{"1 << 4 | 1", 32},
+		{"0xFF & ~ 0x0F", 0xF0},
+		{"3 min 7", 3},
+		{"2 < 3", 1},
+		{"! 0", 1},
+		{"17 % 5", 2},
+	}
+	for _, tt := range tests {
+		p, err := Assemble("test.8o", []byte(":calc X { "+tt.expr+" }"))
+		if err != nil {
+			t.Errorf("calc %q: %v", tt.expr, err)
+			continue
+		}
+		if p.Consts["X"] != tt.exp {
+			t.Errorf("calc %q = %d, expected %d", tt.expr, p.Consts["X"], tt.exp)
+		}
+	}
+}
+
+// run assembles src, runs it until it halts and returns the registers.
+func run(t *testing.T, src string) [emulator.Registers]byte {
+	t.Helper()
+	p := assemble(t, src)
+	e := emulator.NewEmulator(emulator.QuirksXOCHIP)
+	if err := e.LoadROM(bytes.NewReader(p.ROM)); err != nil {
+		t.Fatalf("LoadROM() = %v, expected nil", err)
+	}
+	for n := 0; n < 10000; n++ {
+		if _, err := e.Step(); err != nil {
+			if !errors.Is(err, emulator.ErrHalted) {
+				t.Fatalf("Step() = %v, expected ErrHalted", err)
+			}
+			return e.State().V
+		}
+	}
+	t.Fatalf("program did not halt")
+	return [emulator.Registers]byte{}
+}
+
+func TestComparisons(t *testing.T) {
+	ops := []string{"==", "!=", "<", ">", "<=", ">="}
+	values := [][2]int{{3, 5}, {5, 5}, {5, 3}, {0, 255}}
+	for _, op := range ops {
+		for _, v := range values {
+			for _, rhs := range []string{"v2", "B"} {
+				src := strings.NewReplacer("OP", op, "RHS", rhs).Replace(`
+:const B ` + strconv.Itoa(v[1]) + `
+: main
+	v1 := ` + strconv.Itoa(v[0]) + `
+	v2 := B
+	v3 := 0
+	v4 := 0
+	if v1 OP RHS then v3 := 1
+	if v1 OP RHS begin
+		v4 := 1
+	else
+		v4 := 2
+	end
+	exit
+`)
+				regs := run(t, src)
+				exp := compare(op, v[0], v[1])
+				exp4 := byte(2)
+				if exp == 1 {
+					exp4 = 1
+				}
+				if regs[3] != exp || regs[4] != exp4 {
+					t.Errorf("%d %s %d (%s): then = %d, begin = %d, expected %d, %d", v[0], op, v[1], rhs, regs[3], regs[4], exp, exp4)
+				}
+			}
+		}
+	}
+}
+
+func compare(op string, a, b int) byte {
+	r := map[string]bool{"==": a == b, "!=": a != b, "<": a < b, ">": a > b, "<=": a <= b, ">=": a >= b}[op]
+	if r {
+		return 1
+	}
+	return 0
+}
+
+func TestLoops(t *testing.T) {
+	regs := run(t, `
+: main
+	v0 := 0
+	v1 := 0
+	loop
+		v0 += 1
+		while v0 != 10
+		if v0 == 5 then v1 += 100
+		v1 += 1
+	again
+	exit
+`)
+	if regs[0] != 10 || regs[1] != 109 {
+		t.Errorf("v0, v1 = %d, %d, expected 10, 109", regs[0], regs[1])
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+		msg  string
+	}{
+		{": main\n\tv0 := 300\n", 2, "does not fit in a byte"},
+		{": main\n\n\tjump nowhere\n", 3, `undefined label "nowhere"`},
+		{": main\n\tsprite v0 v1\n", 2, "unexpected end of file"},
+		{": main\n: main\n", 2, "already defined"},
+		{": main\n\tloop\n\tv0 += 1\n", 2, "loop without again"},
+		{": main\n\tif v0 == 1 begin\n", 2, "without end"},
+		{": main\n\tv0 += v1 v1 :=\n", 2, "unexpected end of file"},
+		{": main\n\tif vf < 3 then\n", 2, "vf cannot be used"},
+		{":calc X { 1 / 0 }", 1, "invalid operands"},
+		{"clear", 1, "main is not defined"},
+		{": main\n\telse\n", 2, "else without"},
+		{":macro m { m }\n: main m\n", 1, "nested too deeply"},
+		{":macro set r v { r := v }\n: main\n\n\n\tset v0 300\n", 5, "does not fit in a byte"},
+		{": main\n\tv0 :=\n\t300\n", 3, "does not fit in a byte"},
+		{": main\n\tjump\n\tnowhere\n", 3, `undefined label "nowhere"`},
+		{": main\n:calc X { ( 1 + 2\n\n}\n", 2, "missing )"},
+	}
+	for _, tt := range tests {
+		_, err := Assemble("test.8o", []byte(tt.src))
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Assemble(%q) = %v, expected an *Error", tt.src, err)
+			continue
+		}
+		if e.Line != tt.line || !strings.Contains(e.Msg, tt.msg) {
+			t.Errorf("Assemble(%q) = %v, expected line %d: %s", tt.src, err, tt.line, tt.msg)
+		}
+	}
+}
+
+func TestWriteSymbols(t *testing.T) {
+	p := assemble(t, ":const B 7 :const A -1 : main 1 2 : end")
+	var buf bytes.Buffer
+	p.WriteSymbols(&buf)
+	exp := "label main 0x0200\nlabel end 0x0202\nconst A -1\nconst B 7\n"
+	if buf.String() != exp {
+		t.Errorf("WriteSymbols() = %q, expected %q", buf.String(), exp)
+	}
+}
+
+// Test that Octo listings from the disassembler assemble back to the ROM.
+func TestDisassemblyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		rom    []byte
+		linear bool
+	}{
+		{"program", []byte{
+			0x00, 0xE0, 0x6A, 0x02, 0xA2, 0x14, 0x22, 0x10,
+			0x3A, 0x00, 0xF0, 0x00, 0x02, 0x14, 0x7A, 0xFF,
+			0xD0, 0x15, 0x12, 0x02, 0xF0, 0x90, 0xF0, 0x90,
+			0xF0, 0xFF,
+		}, false},
+		{"plane out of range", []byte{0xF8, 0x01, 0xF3, 0x01, 0x12, 0x04}, false},
+		{"data at origin", []byte{0xFF, 0xFF, 0x60, 0x01, 0x12, 0x04}, false},
+		{"linear data at origin", []byte{0xFF, 0xFF, 0x60, 0x01, 0x12, 0x04}, true},
+	}
+	for _, tt := range tests {
+		p := disasm.Trace(tt.rom, emulator.ProgramAddress, emulator.VariantXOCHIP)
+		if tt.linear {
+			p = disasm.Linear(tt.rom, emulator.ProgramAddress, emulator.VariantXOCHIP)
+		}
+		var src bytes.Buffer
+		p.Write(&src, disasm.Octo)
+		q, err := Assemble("roundtrip.8o", src.Bytes())
+		if err != nil {
+			t.Errorf("%s: Assemble() = %v\nsource:\n%s", tt.name, err, src.String())
+			continue
+		}
+		if !bytes.Equal(q.ROM, tt.rom) {
+			t.Errorf("%s: ROM = % X, expected % X\nsource:\n%s", tt.name, q.ROM, tt.rom, src.String())
+		}
+	}
+}
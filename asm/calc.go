@@ -0,0 +1,144 @@
+package asm
+
+// binaryOps holds the binary operators allowed in :calc expressions.
+var binaryOps = map[string]func(a, b int) (int, bool){
+	"+":  func(a, b int) (int, bool) { return a + b, true },
+	"-":  func(a, b int) (int, bool) { return a - b, true },
+	"*":  func(a, b int) (int, bool) { return a * b, true },
+	"/":  func(a, b int) (int, bool) { return div(a, b) },
+	"%":  func(a, b int) (int, bool) { return mod(a, b) },
+	"&":  func(a, b int) (int, bool) { return a & b, true },
+	"|":  func(a, b int) (int, bool) { return a | b, true },
+	"^":  func(a, b int) (int, bool) { return a ^ b, true },
+	"<<": func(a, b int) (int, bool) { return a << uint(b), b >= 0 },
+	">>": func(a, b int) (int, bool) { return a >> uint(b), b >= 0 },
+	"<":  func(a, b int) (int, bool) { return boolValue(a < b), true },
+	">":  func(a, b int) (int, bool) { return boolValue(a > b), true },
+	"<=": func(a, b int) (int, bool) { return boolValue(a <= b), true },
+	">=": func(a, b int) (int, bool) { return boolValue(a >= b), true },
+	"==": func(a, b int) (int, bool) { return boolValue(a == b), true },
+	"!=": func(a, b int) (int, bool) { return boolValue(a != b), true },
+	"min": func(a, b int) (int, bool) {
+		if a < b {
+			return a, true
+		}
+		return b, true
+	},
+	"max": func(a, b int) (int, bool) {
+		if a > b {
+			return a, true
+		}
+		return b, true
+	},
+}
+
+func div(a, b int) (int, bool) {
+	if b == 0 {
+		return 0, false
+	}
+	return a / b, true
+}
+
+func mod(a, b int) (int, bool) {
+	if b == 0 {
+		return 0, false
+	}
+	return a % b, true
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// calc evaluates the expression in toks, which excludes the enclosing braces.
+// As in Octo, operators have no precedence and are evaluated from right to
+// left, so 2 * 3 + 1 is 8; parentheses group subexpressions.
+func (a *assembler) calc(toks []token, line int) (int, error) {
+	c := calculator{a: a, toks: toks, line: line}
+	v, err := c.expr()
+	if err != nil {
+		return 0, err
+	}
+	if c.pos < len(toks) {
+		return 0, a.errorf(toks[c.pos].line, "unexpected %q in expression", toks[c.pos].text)
+	}
+	return v, nil
+}
+
+// calculator holds the state of the evaluation of one expression.
+type calculator struct {
+	a    *assembler
+	toks []token
+	pos  int
+	line int
+}
+
+func (c *calculator) next() (token, error) {
+	if c.pos >= len(c.toks) {
+		return token{}, c.a.errorf(c.line, "incomplete expression")
+	}
+	t := c.toks[c.pos]
+	c.pos++
+	return t, nil
+}
+
+// expr parses a term followed by an optional operator and expression.
+func (c *calculator) expr() (int, error) {
+	v, err := c.term()
+	if err != nil || c.pos >= len(c.toks) || c.toks[c.pos].text == ")" {
+		return v, err
+	}
+	op, _ := c.next()
+	fn, ok := binaryOps[op.text]
+	if !ok {
+		return 0, c.a.errorf(op.line, "unknown operator %q", op.text)
+	}
+	w, err := c.expr()
+	if err != nil {
+		return 0, err
+	}
+	r, ok := fn(v, w)
+	if !ok {
+		return 0, c.a.errorf(op.line, "invalid operands %d %s %d", v, op.text, w)
+	}
+	return r, nil
+}
+
+// term parses a value, a parenthesised expression or a unary operator and
+// its operand.
+func (c *calculator) term() (int, error) {
+	t, err := c.next()
+	if err != nil {
+		return 0, err
+	}
+	switch t.text {
+	case "(":
+		v, err := c.expr()
+		if err != nil {
+			return 0, err
+		}
+		if end, err := c.next(); err != nil || end.text != ")" {
+			return 0, c.a.errorf(t.line, "missing )")
+		}
+		return v, nil
+	case "-":
+		v, err := c.term()
+		return -v, err
+	case "~":
+		v, err := c.term()
+		return ^v, err
+	case "!":
+		v, err := c.term()
+		return boolValue(v == 0), err
+	case "HERE":
+		return c.a.here, nil
+	}
+	v, ok := c.a.lookup(t.text)
+	if !ok {
+		return 0, c.a.errorf(t.line, "undefined name %q in expression", t.text)
+	}
+	return v, nil
+}
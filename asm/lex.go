@@ -0,0 +1,65 @@
+package asm
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// token holds one word of the source and the line it is on. Tokens produced
+// by expanding a macro record how deeply the expansion is nested.
+type token struct {
+	text  string
+	line  int
+	depth int
+}
+
+// tokenize splits src into whitespace-separated tokens, dropping comments,
+// which run from a # to the end of the line.
+func tokenize(src string) []token {
+	var toks []token
+	for n, line := range strings.Split(src, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		for _, f := range strings.FieldsFunc(line, unicode.IsSpace) {
+			toks = append(toks, token{text: f, line: n + 1})
+		}
+	}
+	return toks
+}
+
+// parseNumber parses a decimal, 0x hexadecimal or 0b binary number, which
+// may be negative. Unlike Go, a leading 0 does not make a number octal.
+func parseNumber(s string) (int, bool) {
+	t := strings.TrimPrefix(s, "-")
+	base := 10
+	if len(t) > 2 && t[0] == '0' {
+		switch t[1] {
+		case 'x', 'X':
+			base, t = 16, t[2:]
+		case 'b', 'B':
+			base, t = 2, t[2:]
+		}
+	}
+	n, err := strconv.ParseInt(t, base, 32)
+	if err != nil {
+		return 0, false
+	}
+	if s[0] == '-' {
+		n = -n
+	}
+	return int(n), true
+}
+
+// parseRegister parses a register name, v0 to vF in either case.
+func parseRegister(s string) (byte, bool) {
+	if len(s) != 2 || s[0] != 'v' && s[0] != 'V' {
+		return 0, false
+	}
+	r, err := strconv.ParseUint(s[1:], 16, 4)
+	if err != nil {
+		return 0, false
+	}
+	return byte(r), true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/markcol/chip8-go/asm"
+)
+
+func asmCommand(args []string) int {
+	fs := flag.NewFlagSet("asm", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chip8 asm [flags] source.8o")
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "", "name of the ROM to write; defaults to the source name with a .ch8 extension")
+	sym := fs.String("sym", "", "write the symbol table to the named file")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	src := fs.Arg(0)
+	if *out == "" {
+		*out = strings.TrimSuffix(src, ".8o") + ".ch8"
+	}
+
+	text, err := ioutil.ReadFile(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		return exitFault
+	}
+	p, err := asm.Assemble(src, text)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFault
+	}
+	if err := ioutil.WriteFile(*out, p.ROM, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		return exitFault
+	}
+	if *sym != "" {
+		if err := writeSymbols(p, *sym); err != nil {
+			fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+			return exitFault
+		}
+	}
+	return exitOK
+}
+
+// writeSymbols writes the symbol table of p to the named file.
+func writeSymbols(p *asm.Program, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteSymbols(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
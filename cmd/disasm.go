@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/markcol/chip8-go/disasm"
+)
+
+func disasmCommand(args []string) int {
+	fs := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chip8 disasm [flags] rom.ch8")
+		fs.PrintDefaults()
+	}
+	quirks := fs.String("quirks", "xochip", "profile selecting the instruction set and load address: vip, eti660, chip48, schip or xochip")
+	syntax := fs.String("syntax", "classic", "mnemonics to write: classic or octo")
+	linear := fs.Bool("linear", false, "decode every word as an instruction instead of following the flow of control")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	p, ok := profiles[*quirks]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "chip8: unknown quirks profile %q\n", *quirks)
+		return exitUsage
+	}
+	s, ok := disasm.Syntaxes[*syntax]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "chip8: unknown syntax %q\n", *syntax)
+		return exitUsage
+	}
+
+	rom, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		return exitFault
+	}
+	var prog *disasm.Program
+	if *linear {
+		prog = disasm.Linear(rom, p.start, p.quirks.Variant)
+	} else {
+		prog = disasm.Trace(rom, p.start, p.quirks.Variant)
+	}
+	if err := prog.Write(os.Stdout, s); err != nil {
+		fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		return exitFault
+	}
+	return exitOK
+}
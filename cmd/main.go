@@ -1,5 +1,55 @@
+// Command chip8 runs CHIP-8 programs.
+//
+// Usage:
+//
+//	chip8 run [flags] rom.ch8
+//	chip8 disasm [flags] rom.ch8
+//	chip8 asm [flags] source.8o
 package main
 
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Exit codes returned by the commands.
+const (
+	exitOK    = 0
+	exitFault = 1
+	exitUsage = 2
+)
+
+// commands holds the subcommands, indexed by name.
+var commands = map[string]func(args []string) int{
+	"run":    runCommand,
+	"disasm": disasmCommand,
+	"asm":    asmCommand,
+}
+
 func main() {
-	// e := emulator.NewEmulator()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "chip8: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(exitUsage)
+	}
+	os.Exit(cmd(os.Args[2:]))
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "usage: chip8 <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
 }
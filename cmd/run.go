@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/markcol/chip8-go/disasm"
+	"github.com/markcol/chip8-go/emulator"
+	"github.com/markcol/chip8-go/term"
+)
+
+// profile holds the emulator settings used to run a family of ROMs.
+type profile struct {
+	quirks emulator.Quirks
+	start  uint16
+	font   emulator.FontSet
+}
+
+var profiles = map[string]profile{
+	"vip":    {emulator.QuirksVIP, emulator.ProgramAddress, emulator.FontVIP},
+	"eti660": {emulator.QuirksVIP, emulator.ETI660ProgramAddress, emulator.FontETI660},
+	"chip48": {emulator.QuirksCHIP48, emulator.ProgramAddress, emulator.FontStandard},
+	"schip":  {emulator.QuirksSCHIP, emulator.ProgramAddress, emulator.FontSCHIP},
+	"xochip": {emulator.QuirksXOCHIP, emulator.ProgramAddress, emulator.FontXOCHIP},
+}
+
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chip8 run [flags] rom.ch8")
+		fs.PrintDefaults()
+	}
+	speed := fs.Int("speed", emulator.DefaultSpeed, "instructions executed per second")
+	quirks := fs.String("quirks", "chip48", "quirks profile: vip, eti660, chip48, schip or xochip")
+	scale := fs.Int("scale", 1, "scale of each pixel in the terminal")
+	render := fs.String("render", "halfblock", "terminal characters to draw with: halfblock or braille")
+	keymap := fs.String("keymap", term.DefaultKeymap, "the 16 keyboard keys mapped to keypad keys 0-F")
+	headless := fs.Bool("headless", false, "run without a display and print the final registers")
+	cycles := fs.Int("cycles", 0, "instructions to execute in headless mode; 0 runs until the program halts")
+	wav := fs.String("wav", "", "record the sound to the named WAV file")
+	gif := fs.String("gif", "", "record the display to the named animated GIF file")
+	y4m := fs.String("y4m", "", "record the display to the named YUV4MPEG2 video file")
+	shot := fs.String("png", "", "save the display to the named PNG file on exit")
+	imageScale := fs.Int("imagescale", 4, "image pixels per pixel in PNG, GIF and video files")
+	recompile := fs.Bool("recompile", false, "translate straight-line code to Go closures for speed")
+	trace := fs.Bool("trace", false, "write each instruction executed to standard error")
+	flags := fs.String("flags", defaultFlagsDir(), "directory holding the SUPER-CHIP user flags saved by each ROM")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	p, ok := profiles[*quirks]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "chip8: unknown quirks profile %q\n", *quirks)
+		return exitUsage
+	}
+	mode, ok := term.Modes[*render]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "chip8: unknown render mode %q\n", *render)
+		return exitUsage
+	}
+	if len(*keymap) != emulator.Keys {
+		fmt.Fprintf(os.Stderr, "chip8: keymap must have %d keys, got %d\n", emulator.Keys, len(*keymap))
+		return exitUsage
+	}
+	if *scale < 1 || *imageScale < 1 || *speed < 1 || *cycles < 0 {
+		fmt.Fprintln(os.Stderr, "chip8: -scale, -imagescale and -speed must be positive and -cycles not negative")
+		return exitUsage
+	}
+
+	e := emulator.NewEmulator(p.quirks)
+	e.SetStartAddress(p.start)
+	e.SetFont(p.font)
+	e.SetSpeed(*speed)
+	e.SetRecompiler(*recompile)
+	if *flags != "" {
+		if err := os.MkdirAll(*flags, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "chip8: flags: %v\n", err)
+			return exitFault
+		}
+		if err := e.SetFlagStore(emulator.FileFlagStore{Dir: *flags}); err != nil {
+			fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+			return exitFault
+		}
+	}
+	if err := e.LoadROMFile(fs.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		return exitFault
+	}
+
+	if *wav != "" {
+		stop, err := recordAudio(e, *wav)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+			return exitFault
+		}
+		defer stop()
+	}
+	var sinks frameSinks
+	if *gif != "" {
+		rec := emulator.NewGIFRecorder(*imageScale, nil)
+		sinks = append(sinks, rec)
+		defer saveGIF(rec, *gif)
+	}
+	if *y4m != "" {
+		f, err := os.Create(*y4m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+			return exitFault
+		}
+		w := bufio.NewWriter(f)
+		defer func() {
+			if err := w.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+			}
+			f.Close()
+		}()
+		sinks = append(sinks, emulator.NewY4MSink(w, *imageScale, nil))
+	}
+	if len(sinks) > 0 {
+		e.SetFrameSink(sinks)
+	}
+	if *trace {
+		w := bufio.NewWriter(os.Stderr)
+		defer w.Flush()
+		e.SetTracer(emulator.TracerFunc(func(pc uint16, in emulator.Instruction) {
+			fmt.Fprintf(w, "%04X  %s\n", pc, disasm.Format(in, disasm.Classic))
+		}))
+	}
+
+	var err error
+	if *headless {
+		err = runHeadless(e, *speed, *cycles)
+		fmt.Println(e.State())
+	} else {
+		err = runTerminal(e, mode, *scale, *keymap)
+	}
+	if *shot != "" {
+		if err := saveImage(e.Frame(), *shot, *imageScale); err != nil {
+			fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		return exitFault
+	}
+	return exitOK
+}
+
+// defaultFlagsDir returns the directory user flags are saved in by default,
+// or "" if there is no suitable directory.
+func defaultFlagsDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chip8", "flags")
+}
+
+// recordAudio records the emulator's sound to the named WAV file. The returned
+// function finishes the file.
+func recordAudio(e *emulator.Emulator, path string) (func(), error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := emulator.NewWAVSink(f, emulator.DefaultSampleRate)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e.SetAudioSink(sink, emulator.DefaultSampleRate)
+	return func() {
+		e.SetAudioSink(nil, 0)
+		if err := sink.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+		}
+		f.Close()
+	}, nil
+}
+
+// frameSinks sends each frame to all of its sinks.
+type frameSinks []emulator.FrameSink
+
+func (s frameSinks) WriteFrame(f emulator.Frame) error {
+	for _, sink := range s {
+		if err := sink.WriteFrame(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveGIF writes the recording to the named file.
+func saveGIF(rec *emulator.GIFRecorder, path string) {
+	f, err := os.Create(path)
+	if err == nil {
+		err = rec.Encode(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chip8: %v\n", err)
+	}
+}
+
+// saveImage writes f to the named PNG file.
+func saveImage(f emulator.Frame, path string, scale int) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.WritePNG(out, scale, nil); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// runHeadless executes cycles instructions, or runs until the program halts
+// if cycles is zero, using the deterministic frame loop.
+func runHeadless(e *emulator.Emulator, speed, cycles int) error {
+	perFrame := speed / emulator.TimerFrequencyHz
+	if perFrame < 1 {
+		perFrame = 1
+	}
+	for done := 0; cycles == 0 || done < cycles; {
+		n := perFrame
+		if cycles > 0 && cycles-done < n {
+			n = cycles - done
+		}
+		k, err := e.RunFrameCount(n)
+		done += k
+		if err != nil {
+			if errors.Is(err, emulator.ErrHalted) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// runTerminal runs the emulator in the terminal until the user quits or the
+// program faults.
+func runTerminal(e *emulator.Emulator, mode term.Mode, scale int, keymap string) error {
+	t, err := term.Open(os.Stdin, os.Stdout, mode, scale, keymap)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	e.SetKeyboard(t)
+	e.AddDisplay(t)
+	defer e.RemoveDisplay(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- e.Run(ctx)
+	}()
+	go func() {
+		t.ReadKeys()
+		cancel()
+	}()
+
+	ticker := time.NewTicker(emulator.TimerFrequency)
+	defer ticker.Stop()
+	quit := ctx.Done()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Refresh(); err != nil {
+				return err
+			}
+		case err := <-done:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			// The program halted; keep showing the display until the user quits.
+			done = nil
+		case <-quit:
+			if done == nil {
+				return nil
+			}
+			// Wait for Run to return.
+			quit = nil
+		}
+	}
+}
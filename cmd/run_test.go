@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// Test that headless mode executes exactly the requested number of
+// instructions when the DisplayWait quirk ends frames early.
+func TestRunHeadlessCycles(t *testing.T) {
+	e := emulator.NewEmulator(emulator.QuirksVIP)
+	e.Write(emulator.ProgramAddress, []byte{
+		0x70, 0x01, // 200: ADD V0,1
+		0xD1, 0x21, // 202: DRW V1,V2,1
+		0x12, 0x00, // 204: JP 0x200
+	})
+
+	if err := runHeadless(e, 660, 30); err != nil {
+		t.Fatalf("runHeadless() = %v, expected nil", err)
+	}
+	if s := e.State(); s.V[0] != 10 || s.PC != emulator.ProgramAddress {
+		t.Errorf("V0 = %d, PC = %#04x, expected 10, %#04x", s.V[0], s.PC, emulator.ProgramAddress)
+	}
+}
@@ -0,0 +1,284 @@
+package disasm
+
+import (
+	"fmt"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// Syntax selects the notation instructions are written in.
+type Syntax int
+
+const (
+	// Classic writes the mnemonics of Cowan's CHIP-8 technical reference,
+	// extended with the SUPER-CHIP and XO-CHIP instructions.
+	Classic Syntax = iota
+
+	// Octo writes Octo assembly language.
+	Octo
+)
+
+// Syntaxes holds the syntaxes, indexed by name.
+var Syntaxes = map[string]Syntax{
+	"classic": Classic,
+	"octo":    Octo,
+}
+
+// Format returns the instruction in the given syntax, with addresses as
+// numbers.
+func Format(in emulator.Instruction, s Syntax) string {
+	return format(in, s, nil)
+}
+
+// format returns the instruction in the given syntax. label returns the name
+// of an address, or "" to write it as a number; it may be nil.
+func format(in emulator.Instruction, s Syntax, label func(addr uint16) string) string {
+	if label == nil {
+		label = func(uint16) string { return "" }
+	}
+	if s == Octo {
+		return octo(in, label)
+	}
+	return classic(in, label)
+}
+
+// address returns the name of a, or a in hex with at least digits digits.
+func address(label func(uint16) string, a uint16, digits int) string {
+	if name := label(a); name != "" {
+		return name
+	}
+	return fmt.Sprintf("0x%0*X", digits, a)
+}
+
+func classic(in emulator.Instruction, label func(uint16) string) string {
+	addr := func(a uint16, digits int) string { return address(label, a, digits) }
+	x := fmt.Sprintf("V%X", in.X)
+	y := fmt.Sprintf("V%X", in.Y)
+	kk := fmt.Sprintf("0x%02X", in.KK)
+	switch in.Op {
+	case emulator.OpSys:
+		return "SYS " + addr(in.NNN, 3)
+	case emulator.OpCls:
+		return "CLS"
+	case emulator.OpRet:
+		return "RET"
+	case emulator.OpScrollDown:
+		return fmt.Sprintf("SCD %d", in.N)
+	case emulator.OpScrollUp:
+		return fmt.Sprintf("SCU %d", in.N)
+	case emulator.OpScrollRight:
+		return "SCR"
+	case emulator.OpScrollLeft:
+		return "SCL"
+	case emulator.OpExit:
+		return "EXIT"
+	case emulator.OpLores:
+		return "LOW"
+	case emulator.OpHires:
+		return "HIGH"
+	case emulator.OpJump:
+		return "JP " + addr(in.NNN, 3)
+	case emulator.OpCall:
+		return "CALL " + addr(in.NNN, 3)
+	case emulator.OpSkipEqByte:
+		return "SE " + x + ", " + kk
+	case emulator.OpSkipNeByte:
+		return "SNE " + x + ", " + kk
+	case emulator.OpSkipEqReg:
+		return "SE " + x + ", " + y
+	case emulator.OpSaveRange:
+		return "LD [I], " + x + "-" + y
+	case emulator.OpLoadRange:
+		return "LD " + x + "-" + y + ", [I]"
+	case emulator.OpLoadByte:
+		return "LD " + x + ", " + kk
+	case emulator.OpAddByte:
+		return "ADD " + x + ", " + kk
+	case emulator.OpMove:
+		return "LD " + x + ", " + y
+	case emulator.OpOr:
+		return "OR " + x + ", " + y
+	case emulator.OpAnd:
+		return "AND " + x + ", " + y
+	case emulator.OpXor:
+		return "XOR " + x + ", " + y
+	case emulator.OpAdd:
+		return "ADD " + x + ", " + y
+	case emulator.OpSub:
+		return "SUB " + x + ", " + y
+	case emulator.OpShr:
+		return "SHR " + x + ", " + y
+	case emulator.OpSubn:
+		return "SUBN " + x + ", " + y
+	case emulator.OpShl:
+		return "SHL " + x + ", " + y
+	case emulator.OpSkipNeReg:
+		return "SNE " + x + ", " + y
+	case emulator.OpLoadI:
+		return "LD I, " + addr(in.NNN, 3)
+	case emulator.OpJumpV0:
+		return "JP V0, " + addr(in.NNN, 3)
+	case emulator.OpRand:
+		return "RND " + x + ", " + kk
+	case emulator.OpDraw:
+		return fmt.Sprintf("DRW %s, %s, %d", x, y, in.N)
+	case emulator.OpSkipKey:
+		return "SKP " + x
+	case emulator.OpSkipNotKey:
+		return "SKNP " + x
+	case emulator.OpLoadILong:
+		return "LD I, LONG " + addr(in.NNN, 4)
+	case emulator.OpAudio:
+		return "AUDIO"
+	case emulator.OpPlane:
+		return fmt.Sprintf("PLANE %d", in.X)
+	case emulator.OpGetDelay:
+		return "LD " + x + ", DT"
+	case emulator.OpWaitKey:
+		return "LD " + x + ", K"
+	case emulator.OpSetDelay:
+		return "LD DT, " + x
+	case emulator.OpSetSound:
+		return "LD ST, " + x
+	case emulator.OpAddI:
+		return "ADD I, " + x
+	case emulator.OpFont:
+		return "LD F, " + x
+	case emulator.OpBigFont:
+		return "LD HF, " + x
+	case emulator.OpBCD:
+		return "LD B, " + x
+	case emulator.OpPitch:
+		return "PITCH " + x
+	case emulator.OpSave:
+		return "LD [I], " + x
+	case emulator.OpLoad:
+		return "LD " + x + ", [I]"
+	case emulator.OpSaveFlags:
+		return "LD R, " + x
+	case emulator.OpLoadFlags:
+		return "LD " + x + ", R"
+	}
+	return fmt.Sprintf("DW 0x%04X", in.Opcode)
+}
+
+func octo(in emulator.Instruction, label func(uint16) string) string {
+	addr := func(a uint16, digits int) string { return address(label, a, digits) }
+	x := fmt.Sprintf("v%x", in.X)
+	y := fmt.Sprintf("v%x", in.Y)
+	kk := fmt.Sprintf("0x%02X", in.KK)
+	switch in.Op {
+	case emulator.OpCls:
+		return "clear"
+	case emulator.OpRet:
+		return "return"
+	case emulator.OpScrollDown:
+		return fmt.Sprintf("scroll-down %d", in.N)
+	case emulator.OpScrollUp:
+		return fmt.Sprintf("scroll-up %d", in.N)
+	case emulator.OpScrollRight:
+		return "scroll-right"
+	case emulator.OpScrollLeft:
+		return "scroll-left"
+	case emulator.OpExit:
+		return "exit"
+	case emulator.OpLores:
+		return "lores"
+	case emulator.OpHires:
+		return "hires"
+	case emulator.OpJump:
+		return "jump " + addr(in.NNN, 3)
+	case emulator.OpCall:
+		if name := label(in.NNN); name != "" {
+			// Octo calls a subroutine by naming it.
+			return name
+		}
+		return ":call " + addr(in.NNN, 3)
+	case emulator.OpSkipEqByte:
+		return "if " + x + " != " + kk + " then"
+	case emulator.OpSkipNeByte:
+		return "if " + x + " == " + kk + " then"
+	case emulator.OpSkipEqReg:
+		return "if " + x + " != " + y + " then"
+	case emulator.OpSaveRange:
+		return "save " + x + " - " + y
+	case emulator.OpLoadRange:
+		return "load " + x + " - " + y
+	case emulator.OpLoadByte:
+		return x + " := " + kk
+	case emulator.OpAddByte:
+		return x + " += " + kk
+	case emulator.OpMove:
+		return x + " := " + y
+	case emulator.OpOr:
+		return x + " |= " + y
+	case emulator.OpAnd:
+		return x + " &= " + y
+	case emulator.OpXor:
+		return x + " ^= " + y
+	case emulator.OpAdd:
+		return x + " += " + y
+	case emulator.OpSub:
+		return x + " -= " + y
+	case emulator.OpShr:
+		return x + " >>= " + y
+	case emulator.OpSubn:
+		return x + " =- " + y
+	case emulator.OpShl:
+		return x + " <<= " + y
+	case emulator.OpSkipNeReg:
+		return "if " + x + " == " + y + " then"
+	case emulator.OpLoadI:
+		return "i := " + addr(in.NNN, 3)
+	case emulator.OpJumpV0:
+		return "jump0 " + addr(in.NNN, 3)
+	case emulator.OpRand:
+		return x + " := random " + kk
+	case emulator.OpDraw:
+		return fmt.Sprintf("sprite %s %s %d", x, y, in.N)
+	case emulator.OpSkipKey:
+		return "if " + x + " -key then"
+	case emulator.OpSkipNotKey:
+		return "if " + x + " key then"
+	case emulator.OpLoadILong:
+		return "i := long " + addr(in.NNN, 4)
+	case emulator.OpAudio:
+		return "audio"
+	case emulator.OpPlane:
+		if in.X > 3 {
+			// Octo only has planes 0 to 3, so other values are written as
+			// data.
+			break
+		}
+		return fmt.Sprintf("plane %d", in.X)
+	case emulator.OpGetDelay:
+		return x + " := delay"
+	case emulator.OpWaitKey:
+		return x + " := key"
+	case emulator.OpSetDelay:
+		return "delay := " + x
+	case emulator.OpSetSound:
+		return "buzzer := " + x
+	case emulator.OpAddI:
+		return "i += " + x
+	case emulator.OpFont:
+		return "i := hex " + x
+	case emulator.OpBigFont:
+		return "i := bighex " + x
+	case emulator.OpBCD:
+		return "bcd " + x
+	case emulator.OpPitch:
+		return "pitch := " + x
+	case emulator.OpSave:
+		return "save " + x
+	case emulator.OpLoad:
+		return "load " + x
+	case emulator.OpSaveFlags:
+		return "saveflags " + x
+	case emulator.OpLoadFlags:
+		return "loadflags " + x
+	}
+	// Octo has no mnemonic for machine code calls, so they are written as
+	// data like invalid opcodes and out of range planes.
+	return fmt.Sprintf("0x%02X 0x%02X", in.Opcode>>8, in.Opcode&0xFF)
+}
@@ -0,0 +1,25 @@
+// Package disasm disassembles CHIP-8, SUPER-CHIP and XO-CHIP programs,
+// producing either classic mnemonics or Octo source. Instructions are decoded
+// by emulator.Decode, so the disassembly always agrees with the interpreter.
+package disasm
+
+import (
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// Decode decodes the instruction at mem[addr] for the given variant,
+// including the address that follows the opcode of a long load of I. It
+// returns false if mem ends before the end of the instruction.
+func Decode(mem []byte, addr int, v emulator.Variant) (emulator.Instruction, bool) {
+	if addr < 0 || addr+1 >= len(mem) {
+		return emulator.Instruction{}, false
+	}
+	in := emulator.Decode(uint16(mem[addr])<<8|uint16(mem[addr+1]), v)
+	if in.Op == emulator.OpLoadILong {
+		if addr+3 >= len(mem) {
+			return emulator.Instruction{}, false
+		}
+		in.NNN = uint16(mem[addr+2])<<8 | uint16(mem[addr+3])
+	}
+	return in, true
+}
@@ -0,0 +1,69 @@
+package disasm
+
+import (
+	"testing"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+func TestDecode(t *testing.T) {
+	mem := []byte{0xF0, 0x00, 0x12, 0x34, 0x00}
+	in, ok := Decode(mem, 0, emulator.VariantXOCHIP)
+	if !ok || in.Op != emulator.OpLoadILong || in.NNN != 0x1234 || in.Size != 4 {
+		t.Errorf("Decode() = %+v, %v, expected LoadILong of 0x1234", in, ok)
+	}
+	if _, ok := Decode(mem[:3], 0, emulator.VariantXOCHIP); ok {
+		t.Errorf("Decode() of a truncated long load = true, expected false")
+	}
+	if _, ok := Decode(mem, 4, emulator.VariantXOCHIP); ok {
+		t.Errorf("Decode() of the last byte = true, expected false")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		opcode        uint16
+		classic, octo string
+	}{
+		{0x00E0, "CLS", "clear"},
+		{0x00EE, "RET", "return"},
+		{0x00C3, "SCD 3", "scroll-down 3"},
+		{0x00FF, "HIGH", "hires"},
+		{0x0123, "SYS 0x123", "0x01 0x23"},
+		{0x1234, "JP 0x234", "jump 0x234"},
+		{0x2345, "CALL 0x345", ":call 0x345"},
+		{0x3A12, "SE VA, 0x12", "if va != 0x12 then"},
+		{0x4A12, "SNE VA, 0x12", "if va == 0x12 then"},
+		{0x5AB0, "SE VA, VB", "if va != vb then"},
+		{0x5AB3, "LD VA-VB, [I]", "load va - vb"},
+		{0x6A12, "LD VA, 0x12", "va := 0x12"},
+		{0x8AB4, "ADD VA, VB", "va += vb"},
+		{0x8AB7, "SUBN VA, VB", "va =- vb"},
+		{0x8ABE, "SHL VA, VB", "va <<= vb"},
+		{0x9AB0, "SNE VA, VB", "if va == vb then"},
+		{0xA123, "LD I, 0x123", "i := 0x123"},
+		{0xB123, "JP V0, 0x123", "jump0 0x123"},
+		{0xCA0F, "RND VA, 0x0F", "va := random 0x0F"},
+		{0xD12F, "DRW V1, V2, 15", "sprite v1 v2 15"},
+		{0xE19E, "SKP V1", "if v1 -key then"},
+		{0xE1A1, "SKNP V1", "if v1 key then"},
+		{0xF301, "PLANE 3", "plane 3"},
+		{0xF801, "PLANE 8", "0xF8 0x01"},
+		{0xF107, "LD V1, DT", "v1 := delay"},
+		{0xF118, "LD ST, V1", "buzzer := v1"},
+		{0xF130, "LD HF, V1", "i := bighex v1"},
+		{0xF133, "LD B, V1", "bcd v1"},
+		{0xF155, "LD [I], V1", "save v1"},
+		{0xF185, "LD V1, R", "loadflags v1"},
+		{0xFFFF, "DW 0xFFFF", "0xFF 0xFF"},
+	}
+	for _, tt := range tests {
+		in := emulator.Decode(tt.opcode, emulator.VariantXOCHIP)
+		if s := Format(in, Classic); s != tt.classic {
+			t.Errorf("%04X in classic syntax = %q, expected %q", tt.opcode, s, tt.classic)
+		}
+		if s := Format(in, Octo); s != tt.octo {
+			t.Errorf("%04X in Octo syntax = %q, expected %q", tt.opcode, s, tt.octo)
+		}
+	}
+}
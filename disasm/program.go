@@ -0,0 +1,232 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// dataPerLine holds the maximum number of data bytes written on one line of a
+// listing.
+const dataPerLine = 4
+
+// Program holds a ROM divided into instructions and data.
+type Program struct {
+	// Origin holds the address the ROM is loaded at.
+	Origin uint16
+
+	// Variant holds the instruction set the ROM is decoded with.
+	Variant emulator.Variant
+
+	// ROM holds the contents of the ROM.
+	ROM []byte
+
+	// code records the offsets in ROM at which instructions start.
+	code map[int]emulator.Instruction
+
+	// labels holds the names of the addresses referred to by jumps, calls
+	// and loads of I that fall on a line of the listing.
+	labels map[uint16]string
+}
+
+// Linear disassembles the ROM loaded at origin as one instruction after
+// another, treating every whole word as code.
+func Linear(rom []byte, origin uint16, v emulator.Variant) *Program {
+	p := newProgram(rom, origin, v)
+	for off := 0; ; {
+		in, ok := Decode(rom, off, v)
+		if !ok {
+			break
+		}
+		if in.Op != emulator.OpInvalid {
+			p.code[off] = in
+			off += in.Size
+		} else {
+			off += 2
+		}
+	}
+	p.findLabels()
+	return p
+}
+
+// Trace disassembles the ROM loaded at origin by following the flow of
+// control from origin and from any other entry points given. Instructions
+// reached through jumps, calls and skips are code and everything else is data.
+// Computed jumps (Bnnn) are followed to nnn only, since the register added to
+// nnn is unknown.
+func Trace(rom []byte, origin uint16, v emulator.Variant, entries ...uint16) *Program {
+	p := newProgram(rom, origin, v)
+	work := append([]uint16{origin}, entries...)
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for {
+			off := int(addr) - int(origin)
+			if _, seen := p.code[off]; seen {
+				break
+			}
+			in, ok := Decode(rom, off, v)
+			if !ok || in.Op == emulator.OpInvalid {
+				break
+			}
+			p.code[off] = in
+			next := addr + uint16(in.Size)
+			switch {
+			case in.Op == emulator.OpJump, in.Op == emulator.OpJumpV0, in.Op == emulator.OpCall:
+				work = append(work, in.NNN)
+			case in.IsSkip():
+				// Continue with the next instruction and queue the one after
+				// it, which is reached when the skip is taken.
+				if skipped, ok := Decode(rom, int(next)-int(origin), v); ok {
+					work = append(work, next+uint16(skipped.Size))
+				}
+			}
+			if in.Op == emulator.OpJump || in.Op == emulator.OpJumpV0 || in.Op == emulator.OpRet || in.Op == emulator.OpExit {
+				break
+			}
+			addr = next
+		}
+	}
+	p.findLabels()
+	return p
+}
+
+func newProgram(rom []byte, origin uint16, v emulator.Variant) *Program {
+	return &Program{
+		Origin:  origin,
+		Variant: v,
+		ROM:     rom,
+		code:    make(map[int]emulator.Instruction),
+		labels:  make(map[uint16]string),
+	}
+}
+
+// Instruction returns the instruction at addr, if addr holds code.
+func (p *Program) Instruction(addr uint16) (emulator.Instruction, bool) {
+	in, ok := p.code[int(addr)-int(p.Origin)]
+	return in, ok
+}
+
+// Labels returns the addresses given labels in the listing, in increasing
+// order, and their names.
+func (p *Program) Labels() ([]uint16, map[uint16]string) {
+	addrs := make([]uint16, 0, len(p.labels))
+	names := make(map[uint16]string, len(p.labels))
+	for a, name := range p.labels {
+		addrs = append(addrs, a)
+		names[a] = name
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs, names
+}
+
+// findLabels names the targets of jumps, calls and loads of I that start a
+// line in the listing, and names the origin main. Lines start at each
+// instruction written and at each byte of data, but not inside an
+// instruction.
+func (p *Program) findLabels() {
+	inside := make([]bool, len(p.ROM))
+	for off := 0; off < len(p.ROM); {
+		in, ok := p.code[off]
+		if !ok {
+			off++
+			continue
+		}
+		for k := 1; k < in.Size; k++ {
+			inside[off+k] = true
+		}
+		off += in.Size
+	}
+	for _, in := range p.code {
+		switch in.Op {
+		case emulator.OpJump, emulator.OpJumpV0, emulator.OpCall, emulator.OpLoadI, emulator.OpLoadILong:
+		default:
+			continue
+		}
+		off := int(in.NNN) - int(p.Origin)
+		if off < 0 || off >= len(p.ROM) {
+			continue
+		}
+		if inside[off] {
+			continue
+		}
+		p.labels[in.NNN] = fmt.Sprintf("L%03X", in.NNN)
+	}
+	if len(p.ROM) > 0 {
+		// Octo starts the program at main, so the origin is always named
+		// main, even when it holds data.
+		p.labels[p.Origin] = "main"
+	}
+}
+
+// label returns the name of addr, or "" if it has none.
+func (p *Program) label(addr uint16) string {
+	return p.labels[addr]
+}
+
+// Write writes a listing of the program to w. Classic listings show the
+// address and bytes of each line; Octo listings are Octo source that
+// assembles back to the ROM.
+func (p *Program) Write(w io.Writer, s Syntax) error {
+	b := bufio.NewWriter(w)
+	if s == Octo && p.Origin != emulator.ProgramAddress {
+		fmt.Fprintf(b, ":org 0x%03X\n", p.Origin)
+	}
+	for off := 0; off < len(p.ROM); {
+		addr := p.Origin + uint16(off)
+		if name := p.label(addr); name != "" {
+			if s == Octo {
+				fmt.Fprintf(b, ": %s\n", name)
+			} else {
+				fmt.Fprintf(b, "%s:\n", name)
+			}
+		}
+		if in, ok := p.code[off]; ok {
+			text := format(in, s, p.label)
+			if s == Octo {
+				fmt.Fprintf(b, "\t%s\n", text)
+			} else {
+				fmt.Fprintf(b, "%04X  % -11X  %s\n", addr, p.ROM[off:off+in.Size], text)
+			}
+			off += in.Size
+			continue
+		}
+		n := p.dataRun(off)
+		data := p.ROM[off : off+n]
+		if s == Octo {
+			fmt.Fprintf(b, "\t%s\n", hexBytes(data, " "))
+		} else {
+			fmt.Fprintf(b, "%04X  % -11X  DB %s\n", addr, data, hexBytes(data, ", "))
+		}
+		off += n
+	}
+	return b.Flush()
+}
+
+// dataRun returns the number of data bytes from off to write on one line,
+// stopping at the next instruction or label.
+func (p *Program) dataRun(off int) int {
+	n := 1
+	for ; n < dataPerLine && off+n < len(p.ROM); n++ {
+		if _, ok := p.code[off+n]; ok {
+			break
+		}
+		if p.label(p.Origin+uint16(off+n)) != "" {
+			break
+		}
+	}
+	return n
+}
+
+// hexBytes returns data as 0x-prefixed hex bytes joined by sep.
+func hexBytes(data []byte, sep string) string {
+	s := make([]string, len(data))
+	for i, c := range data {
+		s[i] = fmt.Sprintf("0x%02X", c)
+	}
+	return strings.Join(s, sep)
+}
@@ -0,0 +1,134 @@
+package disasm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// traceROM calls a subroutine, skips over a long load and loops forever,
+// followed by sprite data.
+var traceROM = []byte{
+	0x22, 0x0C, // 200: CALL 20C
+	0x3A, 0x00, // 202: SE VA, 0
+	0xF0, 0x00, // 204: LD I, LONG 0210
+	0x02, 0x10,
+	0x12, 0x08, // 208: JP 208
+	0xFF, 0xFF, // 20A: data
+	0xA2, 0x10, // 20C: LD I, 210
+	0x00, 0xEE, // 20E: RET
+	0xF0, 0x90, // 210: data
+}
+
+func TestTrace(t *testing.T) {
+	p := Trace(traceROM, emulator.ProgramAddress, emulator.VariantXOCHIP)
+	code := map[uint16]bool{0x200: true, 0x202: true, 0x204: true, 0x208: true, 0x20C: true, 0x20E: true}
+	for addr := uint16(0x200); addr < 0x212; addr += 2 {
+		if _, ok := p.Instruction(addr); ok != code[addr] {
+			t.Errorf("Instruction(%03X) = %v, expected %v", addr, ok, code[addr])
+		}
+	}
+
+	addrs, names := p.Labels()
+	exp := []uint16{0x200, 0x208, 0x20C, 0x210}
+	if len(addrs) != len(exp) {
+		t.Fatalf("Labels() = %X, expected %X", addrs, exp)
+	}
+	for i := range exp {
+		if addrs[i] != exp[i] {
+			t.Errorf("Labels()[%d] = %03X, expected %03X", i, addrs[i], exp[i])
+		}
+	}
+	if names[0x200] != "main" || names[0x20C] != "L20C" {
+		t.Errorf("names = %v, expected main at 200 and L20C at 20C", names)
+	}
+}
+
+// Test that the skipped instruction is found when a skip is taken over a long
+// load.
+func TestTraceSkipLong(t *testing.T) {
+	rom := []byte{
+		0x3A, 0x00, // 200: SE VA, 0
+		0xF0, 0x00, // 202: LD I, LONG 0000
+		0x00, 0x00,
+		0x00, 0xFD, // 206: EXIT
+	}
+	p := Trace(rom, emulator.ProgramAddress, emulator.VariantXOCHIP)
+	if in, ok := p.Instruction(0x206); !ok || in.Op != emulator.OpExit {
+		t.Errorf("Instruction(206) = %v, %v, expected EXIT", in, ok)
+	}
+}
+
+func TestLinear(t *testing.T) {
+	p := Linear(traceROM, emulator.ProgramAddress, emulator.VariantCHIP8)
+	// Without XO-CHIP, F000 is invalid and 0210 is a SYS.
+	if _, ok := p.Instruction(0x204); ok {
+		t.Errorf("Instruction(204) is code, expected data")
+	}
+	if in, ok := p.Instruction(0x206); !ok || in.Op != emulator.OpSys {
+		t.Errorf("Instruction(206) = %v, %v, expected SYS", in, ok)
+	}
+	if in, ok := p.Instruction(0x20A); ok {
+		t.Errorf("Instruction(20A) = %v, expected data", in)
+	}
+}
+
+func TestWriteClassic(t *testing.T) {
+	var buf bytes.Buffer
+	Trace(traceROM, emulator.ProgramAddress, emulator.VariantXOCHIP).Write(&buf, Classic)
+	exp := `main:
+0200  22 0C        CALL L20C
+0202  3A 00        SE VA, 0x00
+0204  F0 00 02 10  LD I, LONG L210
+L208:
+0208  12 08        JP L208
+020A  FF FF        DB 0xFF, 0xFF
+L20C:
+020C  A2 10        LD I, L210
+020E  00 EE        RET
+L210:
+0210  F0 90        DB 0xF0, 0x90
+`
+	if buf.String() != exp {
+		t.Errorf("listing =\n%s\nexpected\n%s", buf.String(), exp)
+	}
+}
+
+func TestWriteOcto(t *testing.T) {
+	var buf bytes.Buffer
+	Trace(traceROM, emulator.ETI660ProgramAddress, emulator.VariantXOCHIP).Write(&buf, Octo)
+	// The call and jump lead below 0x600, out of the ROM, so the flow of
+	// control stops at them.
+	exp := `:org 0x600
+: main
+	:call 0x20C
+	if va != 0x00 then
+	i := long 0x0210
+	jump 0x208
+	0xFF 0xFF 0xA2 0x10
+	0x00 0xEE 0xF0 0x90
+`
+	if buf.String() != exp {
+		t.Errorf("listing =\n%s\nexpected\n%s", buf.String(), exp)
+	}
+
+	buf.Reset()
+	Trace(traceROM, emulator.ProgramAddress, emulator.VariantXOCHIP).Write(&buf, Octo)
+	exp = `: main
+	L20C
+	if va != 0x00 then
+	i := long L210
+: L208
+	jump L208
+	0xFF 0xFF
+: L20C
+	i := L210
+	return
+: L210
+	0xF0 0x90
+`
+	if buf.String() != exp {
+		t.Errorf("listing =\n%s\nexpected\n%s", buf.String(), exp)
+	}
+}
@@ -0,0 +1,168 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultSampleRate holds the default audio sample rate in Hz.
+	DefaultSampleRate = 44100
+
+	// DefaultPitch holds the XO-CHIP pitch register value at reset, which
+	// plays the audio pattern at 4000 bits per second.
+	DefaultPitch = 64
+
+	// PatternSize holds the number of bytes in an XO-CHIP audio pattern.
+	PatternSize = 16
+
+	// DefaultToneFrequency holds the default frequency of the buzzer in Hz.
+	DefaultToneFrequency = 440
+
+	// DefaultVolume holds the default volume, as a fraction of full scale.
+	DefaultVolume = 0.25
+)
+
+// defaultPattern is the XO-CHIP audio pattern in effect until a program loads
+// its own: a square wave that sounds at 250Hz at the default pitch.
+var defaultPattern = [PatternSize]byte{
+	0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF,
+	0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF,
+}
+
+// AudioSink receives the sound produced by the emulator as signed 16-bit mono
+// PCM samples. While the sound timer is not running the emulator writes
+// silence, so the samples form a continuous stream at the sink's sample rate.
+type AudioSink interface {
+	WriteSamples(samples []int16) error
+}
+
+// Generator produces a waveform as signed 16-bit PCM samples.
+type Generator interface {
+	Generate(buf []int16)
+}
+
+// SquareWave generates a square wave of the given frequency, which is how the
+// buzzer of the original interpreters sounds.
+type SquareWave struct {
+	Frequency  float64
+	SampleRate int
+	Volume     int16
+
+	// phase holds the position in the current period, from 0 to 1.
+	phase float64
+}
+
+// Generate fills buf with the next samples of the wave.
+func (g *SquareWave) Generate(buf []int16) {
+	step := g.Frequency / float64(g.SampleRate)
+	for i := range buf {
+		if g.phase < 0.5 {
+			buf[i] = g.Volume
+		} else {
+			buf[i] = -g.Volume
+		}
+		g.phase = math.Mod(g.phase+step, 1)
+	}
+}
+
+// PlaybackRate returns the rate, in bits per second, at which an XO-CHIP audio
+// pattern is played for the given pitch register value.
+func PlaybackRate(pitch byte) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// PatternGenerator plays a 128-bit XO-CHIP audio pattern, one bit at a time,
+// as a 1-bit waveform: set bits produce +Volume and clear bits -Volume.
+type PatternGenerator struct {
+	Pattern    [PatternSize]byte
+	Pitch      byte
+	SampleRate int
+	Volume     int16
+
+	// phase holds the position in the pattern, in bits.
+	phase float64
+}
+
+// Generate fills buf with the next samples of the pattern.
+func (g *PatternGenerator) Generate(buf []int16) {
+	step := PlaybackRate(g.Pitch) / float64(g.SampleRate)
+	for i := range buf {
+		bit := int(g.phase)
+		if g.Pattern[bit/8]&(0x80>>uint(bit%8)) != 0 {
+			buf[i] = g.Volume
+		} else {
+			buf[i] = -g.Volume
+		}
+		g.phase = math.Mod(g.phase+step, PatternSize*8)
+	}
+}
+
+// SetTone sets the frequency in Hz of the buzzer and the volume of all sound,
+// as a fraction of full scale between 0 and 1. On XO-CHIP the pitch of the
+// sound is set by the program instead of frequency.
+func (e *Emulator) SetTone(frequency, volume float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tone.Frequency = frequency
+	e.volume = math.Max(0, math.Min(1, volume))
+}
+
+// SetAudioSink sets the sink that receives sampleRate samples per second of
+// sound, generated once per frame. A sampleRate of zero or less selects
+// DefaultSampleRate. Passing a nil sink disables audio generation.
+func (e *Emulator) SetAudioSink(sink AudioSink, sampleRate int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if sampleRate <= 0 {
+		sampleRate = DefaultSampleRate
+	}
+	e.audio = sink
+	e.sampleRate = sampleRate
+	e.sampleDebt = 0
+}
+
+// playAudio writes one frame of samples to the audio sink.
+func (e *Emulator) playAudio() error {
+	if e.audio == nil {
+		return nil
+	}
+	// Carry the fractional samples over so each second has sampleRate samples.
+	e.sampleDebt += e.sampleRate
+	n := e.sampleDebt / TimerFrequencyHz
+	e.sampleDebt %= TimerFrequencyHz
+	if cap(e.samples) < n {
+		e.samples = make([]int16, n)
+	}
+	buf := e.samples[:n]
+	if e.st > 0 {
+		e.generator().Generate(buf)
+	} else {
+		for i := range buf {
+			buf[i] = 0
+		}
+	}
+	if err := e.audio.WriteSamples(buf); err != nil {
+		return fmt.Errorf("audio: %w", err)
+	}
+	return nil
+}
+
+// generator returns the generator for the current variant, configured with
+// the current sound settings.
+func (e *Emulator) generator() Generator {
+	volume := int16(e.volume * math.MaxInt16)
+	if e.quirks.Variant >= VariantXOCHIP {
+		e.gen.Pattern = e.pattern
+		e.gen.Pitch = e.pitch
+		e.gen.SampleRate = e.sampleRate
+		e.gen.Volume = volume
+		return &e.gen
+	}
+	if e.tone.Frequency <= 0 {
+		e.tone.Frequency = DefaultToneFrequency
+	}
+	e.tone.SampleRate = e.sampleRate
+	e.tone.Volume = volume
+	return &e.tone
+}
@@ -0,0 +1,171 @@
+package emulator
+
+import (
+	"math"
+	"testing"
+)
+
+// sampleRecorder is an AudioSink that records the samples written to it.
+type sampleRecorder struct {
+	samples []int16
+	writes  int
+}
+
+func (r *sampleRecorder) WriteSamples(samples []int16) error {
+	r.samples = append(r.samples, samples...)
+	r.writes++
+	return nil
+}
+
+func TestPlaybackRate(t *testing.T) {
+	tests := []struct {
+		pitch byte
+		rate  float64
+	}{
+		{64, 4000},
+		{112, 8000},
+		{16, 2000},
+	}
+	for _, tt := range tests {
+		if r := PlaybackRate(tt.pitch); math.Abs(r-tt.rate) > 1e-9 {
+			t.Errorf("PlaybackRate(%d) = %v, expected %v", tt.pitch, r, tt.rate)
+		}
+	}
+}
+
+func TestPatternGenerator(t *testing.T) {
+	g := &PatternGenerator{
+		Pattern:    [PatternSize]byte{0xA0},
+		Pitch:      DefaultPitch,
+		SampleRate: 8000,
+		Volume:     100,
+	}
+	buf := make([]int16, 8)
+
+	g.Generate(buf)
+
+	exp := []int16{100, 100, -100, -100, 100, 100, -100, -100}
+	for i := range exp {
+		if buf[i] != exp[i] {
+			t.Fatalf("samples = %v, expected %v", buf, exp)
+		}
+	}
+
+	// The pattern repeats after 128 bits.
+	buf = make([]int16, 2*PatternSize*8)
+	g.Generate(buf)
+	if buf[len(buf)-8] != 100 || buf[len(buf)-6] != -100 {
+		t.Errorf("pattern did not repeat: %v", buf[len(buf)-8:])
+	}
+}
+
+func TestAudioSink(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	rec := &sampleRecorder{}
+	e.SetAudioSink(rec, 0)
+
+	for f := 0; f < TimerFrequencyHz; f++ {
+		e.RunFrame(0)
+	}
+	if len(rec.samples) != DefaultSampleRate || rec.writes != TimerFrequencyHz {
+		t.Fatalf("%d samples in %d writes, expected %d in %d", len(rec.samples), rec.writes, DefaultSampleRate, TimerFrequencyHz)
+	}
+	for _, s := range rec.samples {
+		if s != 0 {
+			t.Fatalf("sample = %d, expected silence", s)
+		}
+	}
+
+	rec.samples = nil
+	e.st = 2
+	e.RunFrame(0)
+	e.RunFrame(0)
+	e.RunFrame(0)
+	n := DefaultSampleRate / TimerFrequencyHz
+	for i, s := range rec.samples {
+		if (i < 2*n) != (s != 0) {
+			t.Fatalf("sample %d = %d, expected sound only in the first two frames", i, s)
+		}
+	}
+}
+
+func TestLoadAudioPatternAndPitch(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	pattern := []byte{0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF, 0x01}
+	e.Write(0x300, pattern)
+	e.i = 0x300
+	e.v[5] = 112
+	e.WriteOpcode(0xF002, 0x200) // AUDIO
+	e.WriteOpcode(0xF53A, 0x202) // PITCH V5
+
+	e.Step()
+	e.Step()
+
+	for i := range pattern {
+		if e.pattern[i] != pattern[i] {
+			t.Fatalf("pattern = % x, expected % x", e.pattern, pattern)
+		}
+	}
+	if e.pitch != 112 {
+		t.Errorf("pitch = %d, expected %d", e.pitch, 112)
+	}
+}
+
+func TestSquareWave(t *testing.T) {
+	g := &SquareWave{Frequency: 1000, SampleRate: 8000, Volume: 10}
+	buf := make([]int16, 16)
+
+	g.Generate(buf)
+
+	for i, s := range buf {
+		exp := int16(10)
+		if i%8 >= 4 {
+			exp = -10
+		}
+		if s != exp {
+			t.Fatalf("samples = %v, expected a 1kHz square wave", buf)
+		}
+	}
+}
+
+// Test that Beep sounds the buzzer for one period without cutting short a
+// tone that is already sounding.
+func TestBeep(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.Beep()
+	if e.st != 1 {
+		t.Errorf("ST = %d after Beep, expected 1", e.st)
+	}
+
+	e.st = 30
+	e.Beep()
+	if e.st != 30 {
+		t.Errorf("ST = %d after Beep during a tone, expected 30", e.st)
+	}
+}
+
+// Test that the buzzer sounds a square wave at the configured tone.
+func TestTone(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	rec := &sampleRecorder{}
+	e.SetAudioSink(rec, 8000)
+	e.SetTone(1000, 0.5)
+
+	e.Beep()
+	e.RunFrame(0)
+
+	if len(rec.samples) != 8000/TimerFrequencyHz {
+		t.Fatalf("%d samples, expected %d", len(rec.samples), 8000/TimerFrequencyHz)
+	}
+	volume := 0.5
+	high := int16(volume * math.MaxInt16)
+	for i, s := range rec.samples {
+		exp := high
+		if i%8 >= 4 {
+			exp = -high
+		}
+		if s != exp {
+			t.Fatalf("sample %d = %d, expected %d", i, s, exp)
+		}
+	}
+}
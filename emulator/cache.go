@@ -0,0 +1,71 @@
+package emulator
+
+// SetDecodeCache enables or disables the decoded instruction cache, which is
+// enabled by default. With the cache each address is decoded once and the
+// result reused until the memory it was decoded from is written, so turning
+// it off only makes sense when measuring or debugging the decoder.
+func (e *Emulator) SetDecodeCache(on bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.noCache = !on
+	e.cache = nil
+}
+
+// fetch returns the instruction at the pc and advances the pc past its
+// opcode.
+func (e *Emulator) fetch() Instruction {
+	if e.noCache {
+		in := e.decodeAt(e.pc)
+		e.pc += 2
+		return in
+	}
+	if len(e.cache) != e.memSize() {
+		e.cache = make([]Instruction, e.memSize())
+	}
+	in := e.cache[e.pc]
+	if in.Size == 0 {
+		in = e.decodeAt(e.pc)
+		e.cache[e.pc] = in
+	}
+	e.pc += 2
+	return in
+}
+
+// decodeAt decodes the instruction at addr, including the address that
+// follows the opcode of a long load of I.
+func (e *Emulator) decodeAt(addr uint16) Instruction {
+	in := Decode(e.opcodeAt(addr), e.quirks.Variant)
+	if in.Op == OpLoadILong {
+		in.NNN = e.opcodeAt(addr + 2)
+	}
+	return in
+}
+
+// invalidate drops the cached instructions decoded from any of the n bytes of
+// memory at addr. An instruction is at most 4 bytes long, so this includes
+// those starting up to 3 bytes before addr.
+func (e *Emulator) invalidate(addr, n int) {
+	e.invalidateBlocks(addr, n)
+	size := len(e.cache)
+	if size == 0 {
+		return
+	}
+	if n > size {
+		n = size
+	}
+	for a := addr - 3; a < addr+n; a++ {
+		e.cache[(a+size)%size] = Instruction{}
+	}
+}
+
+// flushCache drops every cached instruction and compiled block, and forgets
+// which code the program modified.
+func (e *Emulator) flushCache() {
+	for a := range e.cache {
+		e.cache[a] = Instruction{}
+	}
+	e.dropBlocks()
+	for a := range e.interpret {
+		e.interpret[a] = false
+	}
+}
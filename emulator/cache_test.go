@@ -0,0 +1,94 @@
+package emulator
+
+import (
+	"testing"
+)
+
+// Test that instructions overwritten by the program itself are decoded again,
+// with the cache on and off.
+func TestDecodeCacheSelfModifyingCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		quirks Quirks
+		store  uint16
+	}{
+		{"Fx55", QuirksCHIP48, 0xF155}, // LD [I],V1
+		{"5xy2", QuirksXOCHIP, 0x5012}, // SAVE V0-V1
+	}
+	for _, tt := range tests {
+		for _, cached := range []bool{true, false} {
+			e := NewEmulator(tt.quirks)
+			e.SetDecodeCache(cached)
+			e.Write(ProgramAddress, []byte{
+				0x61, 0x01, // 200: LD V1,1 (replaced by LD V2,5)
+				0x73, 0x01, // 202: ADD V3,1
+				0x33, 0x01, // 204: SE V3,1
+				0x12, 0x06, // 206: JP 0x206
+				0x60, 0x62, // 208: LD V0,0x62
+				0x61, 0x05, // 20A: LD V1,5
+				0xA2, 0x00, // 20C: LD I,0x200
+				byte(tt.store >> 8), byte(tt.store),
+				0x12, 0x00, // 212: JP 0x200
+			})
+			if err := e.RunFrame(20); err != ErrHalted {
+				t.Fatalf("%s cached=%v: RunFrame() = %v, expected %v", tt.name, cached, err, ErrHalted)
+			}
+			if e.v[1] != 5 || e.v[2] != 5 || e.v[3] != 2 {
+				t.Errorf("%s cached=%v: V1-V3 = % x, expected 05 05 02", tt.name, cached, e.v[1:4])
+			}
+		}
+	}
+}
+
+// Test that writes from outside the program invalidate cached instructions,
+// including long loads whose address is overwritten.
+func TestDecodeCacheWrites(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	e.Write(ProgramAddress, []byte{0xF0, 0x00, 0x12, 0x34, 0x12, 0x00})
+	e.Step()
+	if e.i != 0x1234 {
+		t.Fatalf("I = %#04x, expected %#04x", e.i, 0x1234)
+	}
+	e.Step()
+
+	e.Write(ProgramAddress+3, []byte{0x56})
+	e.Step()
+	if e.i != 0x1256 {
+		t.Errorf("I = %#04x after Write, expected %#04x", e.i, 0x1256)
+	}
+	e.Step()
+
+	e.WriteOpcode(0x6A07, ProgramAddress) // LD VA,7
+	e.Step()
+	if e.v[0xA] != 7 {
+		t.Errorf("VA = %#02x after WriteOpcode, expected %#02x", e.v[0xA], 7)
+	}
+}
+
+// benchmarkROM holds a loop of common instructions.
+var benchmarkROM = []byte{
+	0x60, 0x00, // 200: LD V0,0
+	0x70, 0x01, // 202: ADD V0,1
+	0x81, 0x00, // 204: LD V1,V0
+	0x81, 0x14, // 206: ADD V1,V1
+	0xA3, 0x00, // 208: LD I,0x300
+	0xF0, 0x1E, // 20A: ADD I,V0
+	0x30, 0x40, // 20C: SE V0,0x40
+	0x12, 0x02, // 20E: JP 0x202
+	0x12, 0x00, // 210: JP 0x200
+}
+
+func benchmarkStep(b *testing.B, cached bool) {
+	e := NewEmulator(QuirksCHIP48)
+	e.SetDecodeCache(cached)
+	e.Write(ProgramAddress, benchmarkROM)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := e.Step(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStepDecode(b *testing.B) { benchmarkStep(b, false) }
+func BenchmarkStepCached(b *testing.B) { benchmarkStep(b, true) }
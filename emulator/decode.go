@@ -0,0 +1,276 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+// Op identifies the operation performed by an instruction.
+type Op int
+
+// The operations, in opcode order. The comment on each gives its opcode.
+const (
+	OpInvalid     Op = iota // an opcode not defined by the variant
+	OpSys                   // 0nnn
+	OpCls                   // 00E0
+	OpRet                   // 00EE
+	OpScrollDown            // 00Cn, SUPER-CHIP
+	OpScrollUp              // 00Dn, XO-CHIP
+	OpScrollRight           // 00FB, SUPER-CHIP
+	OpScrollLeft            // 00FC, SUPER-CHIP
+	OpExit                  // 00FD, SUPER-CHIP
+	OpLores                 // 00FE, SUPER-CHIP
+	OpHires                 // 00FF, SUPER-CHIP
+	OpJump                  // 1nnn
+	OpCall                  // 2nnn
+	OpSkipEqByte            // 3xkk
+	OpSkipNeByte            // 4xkk
+	OpSkipEqReg             // 5xy0
+	OpSaveRange             // 5xy2, XO-CHIP
+	OpLoadRange             // 5xy3, XO-CHIP
+	OpLoadByte              // 6xkk
+	OpAddByte               // 7xkk
+	OpMove                  // 8xy0
+	OpOr                    // 8xy1
+	OpAnd                   // 8xy2
+	OpXor                   // 8xy3
+	OpAdd                   // 8xy4
+	OpSub                   // 8xy5
+	OpShr                   // 8xy6
+	OpSubn                  // 8xy7
+	OpShl                   // 8xyE
+	OpSkipNeReg             // 9xy0
+	OpLoadI                 // Annn
+	OpJumpV0                // Bnnn
+	OpRand                  // Cxkk
+	OpDraw                  // Dxyn
+	OpSkipKey               // Ex9E
+	OpSkipNotKey            // ExA1
+	OpLoadILong             // F000 nnnn, XO-CHIP
+	OpAudio                 // F002, XO-CHIP
+	OpPlane                 // Fn01, XO-CHIP
+	OpGetDelay              // Fx07
+	OpWaitKey               // Fx0A
+	OpSetDelay              // Fx15
+	OpSetSound              // Fx18
+	OpAddI                  // Fx1E
+	OpFont                  // Fx29
+	OpBigFont               // Fx30, SUPER-CHIP
+	OpBCD                   // Fx33
+	OpPitch                 // Fx3A, XO-CHIP
+	OpSave                  // Fx55
+	OpLoad                  // Fx65
+	OpSaveFlags             // Fx75, SUPER-CHIP
+	OpLoadFlags             // Fx85, SUPER-CHIP
+)
+
+var opNames = map[Op]string{
+	OpInvalid:     "invalid",
+	OpSys:         "sys",
+	OpCls:         "cls",
+	OpRet:         "ret",
+	OpScrollDown:  "scroll-down",
+	OpScrollUp:    "scroll-up",
+	OpScrollRight: "scroll-right",
+	OpScrollLeft:  "scroll-left",
+	OpExit:        "exit",
+	OpLores:       "lores",
+	OpHires:       "hires",
+	OpJump:        "jump",
+	OpCall:        "call",
+	OpSkipEqByte:  "skip-eq-byte",
+	OpSkipNeByte:  "skip-ne-byte",
+	OpSkipEqReg:   "skip-eq-reg",
+	OpSaveRange:   "save-range",
+	OpLoadRange:   "load-range",
+	OpLoadByte:    "load-byte",
+	OpAddByte:     "add-byte",
+	OpMove:        "move",
+	OpOr:          "or",
+	OpAnd:         "and",
+	OpXor:         "xor",
+	OpAdd:         "add",
+	OpSub:         "sub",
+	OpShr:         "shr",
+	OpSubn:        "subn",
+	OpShl:         "shl",
+	OpSkipNeReg:   "skip-ne-reg",
+	OpLoadI:       "load-i",
+	OpJumpV0:      "jump-v0",
+	OpRand:        "rand",
+	OpDraw:        "draw",
+	OpSkipKey:     "skip-key",
+	OpSkipNotKey:  "skip-not-key",
+	OpLoadILong:   "load-i-long",
+	OpAudio:       "audio",
+	OpPlane:       "plane",
+	OpGetDelay:    "get-delay",
+	OpWaitKey:     "wait-key",
+	OpSetDelay:    "set-delay",
+	OpSetSound:    "set-sound",
+	OpAddI:        "add-i",
+	OpFont:        "font",
+	OpBigFont:     "big-font",
+	OpBCD:         "bcd",
+	OpPitch:       "pitch",
+	OpSave:        "save",
+	OpLoad:        "load",
+	OpSaveFlags:   "save-flags",
+	OpLoadFlags:   "load-flags",
+}
+
+func (op Op) String() string {
+	if n, ok := opNames[op]; ok {
+		return n
+	}
+	return fmt.Sprintf("op(%d)", int(op))
+}
+
+// Instruction holds a decoded instruction and its operands. Fields that the
+// operation does not use are zero.
+type Instruction struct {
+	Op     Op
+	Opcode uint16
+	X      byte   // the x register, or the plane mask of Fn01
+	Y      byte   // the y register
+	N      byte   // the low nibble of Dxyn and the scroll instructions
+	KK     byte   // the low byte
+	NNN    uint16 // the address; the full 16 bits for F000 nnnn
+	Size   int    // the size in bytes: 4 for F000 nnnn, otherwise 2
+}
+
+// operands selects the fields an entry in the decode table fills in.
+type operands int
+
+const (
+	noOperands operands = iota
+	xOperand
+	xyOperands
+	xkkOperands
+	xynOperands
+	nOperand
+	nnnOperand
+)
+
+// decodeEntry matches opcodes with opcode&mask == value, for the given
+// variant and later.
+type decodeEntry struct {
+	mask, value uint16
+	op          Op
+	variant     Variant
+	operands    operands
+}
+
+// decodeTable holds the instruction set. Entries are tried in order, so more
+// specific patterns come before the general ones they overlap. This is the
+// only place opcodes are decoded: the interpreter, the disassembler and
+// tracers all work from the Instruction it produces.
+var decodeTable = []decodeEntry{
+	{0xFFFF, 0x00E0, OpCls, VariantCHIP8, noOperands},
+	{0xFFFF, 0x00EE, OpRet, VariantCHIP8, noOperands},
+	{0xFFF0, 0x00C0, OpScrollDown, VariantSCHIP, nOperand},
+	{0xFFF0, 0x00D0, OpScrollUp, VariantXOCHIP, nOperand},
+	{0xFFFF, 0x00FB, OpScrollRight, VariantSCHIP, noOperands},
+	{0xFFFF, 0x00FC, OpScrollLeft, VariantSCHIP, noOperands},
+	{0xFFFF, 0x00FD, OpExit, VariantSCHIP, noOperands},
+	{0xFFFF, 0x00FE, OpLores, VariantSCHIP, noOperands},
+	{0xFFFF, 0x00FF, OpHires, VariantSCHIP, noOperands},
+	{0xF000, 0x0000, OpSys, VariantCHIP8, nnnOperand},
+	{0xF000, 0x1000, OpJump, VariantCHIP8, nnnOperand},
+	{0xF000, 0x2000, OpCall, VariantCHIP8, nnnOperand},
+	{0xF000, 0x3000, OpSkipEqByte, VariantCHIP8, xkkOperands},
+	{0xF000, 0x4000, OpSkipNeByte, VariantCHIP8, xkkOperands},
+	{0xF00F, 0x5000, OpSkipEqReg, VariantCHIP8, xyOperands},
+	{0xF00F, 0x5002, OpSaveRange, VariantXOCHIP, xyOperands},
+	{0xF00F, 0x5003, OpLoadRange, VariantXOCHIP, xyOperands},
+	{0xF000, 0x6000, OpLoadByte, VariantCHIP8, xkkOperands},
+	{0xF000, 0x7000, OpAddByte, VariantCHIP8, xkkOperands},
+	{0xF00F, 0x8000, OpMove, VariantCHIP8, xyOperands},
+	{0xF00F, 0x8001, OpOr, VariantCHIP8, xyOperands},
+	{0xF00F, 0x8002, OpAnd, VariantCHIP8, xyOperands},
+	{0xF00F, 0x8003, OpXor, VariantCHIP8, xyOperands},
+	{0xF00F, 0x8004, OpAdd, VariantCHIP8, xyOperands},
+	{0xF00F, 0x8005, OpSub, VariantCHIP8, xyOperands},
+	{0xF00F, 0x8006, OpShr, VariantCHIP8, xyOperands},
+	{0xF00F, 0x8007, OpSubn, VariantCHIP8, xyOperands},
+	{0xF00F, 0x800E, OpShl, VariantCHIP8, xyOperands},
+	{0xF00F, 0x9000, OpSkipNeReg, VariantCHIP8, xyOperands},
+	{0xF000, 0xA000, OpLoadI, VariantCHIP8, nnnOperand},
+	{0xF000, 0xB000, OpJumpV0, VariantCHIP8, nnnOperand},
+	{0xF000, 0xC000, OpRand, VariantCHIP8, xkkOperands},
+	{0xF000, 0xD000, OpDraw, VariantCHIP8, xynOperands},
+	{0xF0FF, 0xE09E, OpSkipKey, VariantCHIP8, xOperand},
+	{0xF0FF, 0xE0A1, OpSkipNotKey, VariantCHIP8, xOperand},
+	{0xFFFF, 0xF000, OpLoadILong, VariantXOCHIP, noOperands},
+	{0xFFFF, 0xF002, OpAudio, VariantXOCHIP, noOperands},
+	{0xF0FF, 0xF001, OpPlane, VariantXOCHIP, xOperand},
+	{0xF0FF, 0xF007, OpGetDelay, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF00A, OpWaitKey, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF015, OpSetDelay, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF018, OpSetSound, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF01E, OpAddI, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF029, OpFont, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF030, OpBigFont, VariantSCHIP, xOperand},
+	{0xF0FF, 0xF033, OpBCD, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF03A, OpPitch, VariantXOCHIP, xOperand},
+	{0xF0FF, 0xF055, OpSave, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF065, OpLoad, VariantCHIP8, xOperand},
+	{0xF0FF, 0xF075, OpSaveFlags, VariantSCHIP, xOperand},
+	{0xF0FF, 0xF085, OpLoadFlags, VariantSCHIP, xOperand},
+}
+
+// decodeIndex holds the entries of decodeTable for each value of the high
+// nibble of an opcode, in table order.
+var decodeIndex [16][]decodeEntry
+
+func init() {
+	for _, d := range decodeTable {
+		for n := range decodeIndex {
+			if uint16(n)<<12&d.mask == d.value&0xF000 {
+				decodeIndex[n] = append(decodeIndex[n], d)
+			}
+		}
+	}
+}
+
+// Decode decodes an opcode for the given variant. Opcodes the variant does
+// not define decode as OpInvalid. The address loaded by OpLoadILong is in the
+// word following the opcode, so Decode leaves NNN as 0 for it.
+func Decode(opcode uint16, v Variant) Instruction {
+	in := Instruction{Op: OpInvalid, Opcode: opcode, Size: 2}
+	for _, d := range decodeIndex[opcode>>12] {
+		if opcode&d.mask != d.value || v < d.variant {
+			continue
+		}
+		in.Op = d.op
+		x := byte(opcode>>8) & 0x0F
+		y := byte(opcode>>4) & 0x0F
+		switch d.operands {
+		case xOperand:
+			in.X = x
+		case xyOperands:
+			in.X, in.Y = x, y
+		case xkkOperands:
+			in.X, in.KK = x, byte(opcode)
+		case xynOperands:
+			in.X, in.Y, in.N = x, y, byte(opcode)&0x0F
+		case nOperand:
+			in.N = byte(opcode) & 0x0F
+		case nnnOperand:
+			in.NNN = opcode & 0x0FFF
+		}
+		if d.op == OpLoadILong {
+			in.Size = 4
+		}
+		break
+	}
+	return in
+}
+
+// IsSkip reports whether the instruction conditionally skips the next one.
+func (in Instruction) IsSkip() bool {
+	switch in.Op {
+	case OpSkipEqByte, OpSkipNeByte, OpSkipEqReg, OpSkipNeReg, OpSkipKey, OpSkipNotKey:
+		return true
+	}
+	return false
+}
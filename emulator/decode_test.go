@@ -0,0 +1,71 @@
+package emulator
+
+import (
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		opcode  uint16
+		variant Variant
+		exp     Instruction
+	}{
+		{0x00E0, VariantCHIP8, Instruction{Op: OpCls}},
+		{0x0123, VariantCHIP8, Instruction{Op: OpSys, NNN: 0x123}},
+		{0x00C4, VariantCHIP8, Instruction{Op: OpSys, NNN: 0x0C4}},
+		{0x00C4, VariantSCHIP, Instruction{Op: OpScrollDown, N: 4}},
+		{0x00D4, VariantSCHIP, Instruction{Op: OpSys, NNN: 0x0D4}},
+		{0x00D4, VariantXOCHIP, Instruction{Op: OpScrollUp, N: 4}},
+		{0x3A12, VariantCHIP8, Instruction{Op: OpSkipEqByte, X: 0xA, KK: 0x12}},
+		{0x5AB0, VariantCHIP8, Instruction{Op: OpSkipEqReg, X: 0xA, Y: 0xB}},
+		{0x5AB2, VariantCHIP8, Instruction{Op: OpInvalid}},
+		{0x5AB2, VariantXOCHIP, Instruction{Op: OpSaveRange, X: 0xA, Y: 0xB}},
+		{0x8AB6, VariantCHIP8, Instruction{Op: OpShr, X: 0xA, Y: 0xB}},
+		{0x8AB8, VariantCHIP8, Instruction{Op: OpInvalid}},
+		{0xD125, VariantCHIP8, Instruction{Op: OpDraw, X: 1, Y: 2, N: 5}},
+		{0xE39E, VariantCHIP8, Instruction{Op: OpSkipKey, X: 3}},
+		{0xF000, VariantSCHIP, Instruction{Op: OpInvalid}},
+		{0xF000, VariantXOCHIP, Instruction{Op: OpLoadILong, Size: 4}},
+		{0xF201, VariantXOCHIP, Instruction{Op: OpPlane, X: 2}},
+		{0xF575, VariantCHIP8, Instruction{Op: OpInvalid}},
+		{0xF575, VariantSCHIP, Instruction{Op: OpSaveFlags, X: 5}},
+	}
+	for _, tt := range tests {
+		exp := tt.exp
+		exp.Opcode = tt.opcode
+		if exp.Size == 0 {
+			exp.Size = 2
+		}
+		if in := Decode(tt.opcode, tt.variant); in != exp {
+			t.Errorf("Decode(%04X, %v) = %+v, expected %+v", tt.opcode, tt.variant, in, exp)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.WriteOpcode(0x6012, ProgramAddress)   // LD V0, 0x12
+	e.WriteOpcode(0x7001, ProgramAddress+2) // ADD V0, 1
+	e.WriteOpcode(0x1204, ProgramAddress+4) // JP 0x204
+	var pcs []uint16
+	var ops []Op
+	e.SetTracer(TracerFunc(func(pc uint16, in Instruction) {
+		pcs = append(pcs, pc)
+		ops = append(ops, in.Op)
+	}))
+	for i := 0; i < 3; i++ {
+		e.Step()
+	}
+	expPCs := []uint16{0x200, 0x202, 0x204}
+	expOps := []Op{OpLoadByte, OpAddByte, OpJump}
+	for i := range expPCs {
+		if i >= len(pcs) || pcs[i] != expPCs[i] || ops[i] != expOps[i] {
+			t.Fatalf("traced %04X %v, expected %04X %v", pcs, ops, expPCs, expOps)
+		}
+	}
+	e.SetTracer(nil)
+	e.Step()
+	if len(pcs) != 3 {
+		t.Errorf("traced %d instructions after removing the tracer, expected 3", len(pcs))
+	}
+}
@@ -0,0 +1,249 @@
+package emulator
+
+import (
+	"image"
+)
+
+// Frame holds a copy of the display. Pixels holds one byte per pixel in
+// row-major order; lit pixels are 1 and unlit pixels are 0. On XO-CHIP each
+// pixel holds one bit per bitplane, giving four colours from 0 to 3.
+type Frame struct {
+	Width  int
+	Height int
+	Pixels []byte
+}
+
+// Bounds returns the rectangle covering the whole frame.
+func (f Frame) Bounds() image.Rectangle {
+	return image.Rect(0, 0, f.Width, f.Height)
+}
+
+// At returns the pixel at (x, y).
+func (f Frame) At(x, y int) byte {
+	return f.Pixels[y*f.Width+x]
+}
+
+// Display observes the emulator's display. Update is called after every
+// instruction that changes the display, such as CLS, DRW or a scroll, with a
+// copy of the display and the region that changed. The frame is shared
+// between all displays and must not be modified. Update runs on the goroutine
+// executing the emulator and must neither block nor call back into the
+// emulator.
+type Display interface {
+	Update(f Frame, dirty image.Rectangle)
+}
+
+// DisplayFunc adapts a function to the Display interface.
+type DisplayFunc func(f Frame, dirty image.Rectangle)
+
+// Update calls fn(f, dirty).
+func (fn DisplayFunc) Update(f Frame, dirty image.Rectangle) {
+	fn(f, dirty)
+}
+
+// AddDisplay registers d to be notified of changes to the display. It is
+// immediately sent the current display.
+func (e *Emulator) AddDisplay(d Display) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.displays = append(e.displays, d)
+	f := e.frame()
+	d.Update(f, f.Bounds())
+}
+
+// RemoveDisplay stops notifying d of changes to the display.
+func (e *Emulator) RemoveDisplay(d Display) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, o := range e.displays {
+		if o == d {
+			e.displays = append(e.displays[:i], e.displays[i+1:]...)
+			return
+		}
+	}
+}
+
+// markDirty records that the pixels in r have changed.
+func (e *Emulator) markDirty(r image.Rectangle) {
+	e.dirty = e.dirty.Union(r)
+}
+
+// markAllDirty records that the whole display has changed.
+func (e *Emulator) markAllDirty() {
+	w, h := e.resolution()
+	e.markDirty(image.Rect(0, 0, w, h))
+}
+
+// flushDisplay notifies the displays of the changes since the last flush.
+func (e *Emulator) flushDisplay() {
+	if e.dirty.Empty() {
+		return
+	}
+	if len(e.displays) > 0 {
+		f := e.frame()
+		dirty := e.dirty.Intersect(f.Bounds())
+		for _, d := range e.displays {
+			d.Update(f, dirty)
+		}
+	}
+	e.dirty = image.Rectangle{}
+}
+
+// Framebuffer returns a copy of the display, one byte per pixel in row-major
+// order, with pixel values as described by Frame. The width of the display is
+// reported by Resolution.
+func (e *Emulator) Framebuffer() []byte {
+	return e.Frame().Pixels
+}
+
+// Frame returns a copy of the display together with its resolution.
+func (e *Emulator) Frame() Frame {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.frame()
+}
+
+func (e *Emulator) frame() Frame {
+	w, h := e.resolution()
+	f := Frame{Width: w, Height: h, Pixels: make([]byte, w*h)}
+	copy(f.Pixels, e.display[:w*h])
+	return f
+}
+
+// Resolution returns the current width and height of the display in pixels.
+func (e *Emulator) Resolution() (width, height int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.resolution()
+}
+
+func (e *Emulator) resolution() (width, height int) {
+	if e.hires {
+		return HiresDisplayWidth, HiresDisplayHeight
+	}
+	return DisplayWidth, DisplayHeight
+}
+
+// ClearDisplay sets the display to all 0s.
+func (e *Emulator) ClearDisplay() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clear(0xFF)
+	e.flushDisplay()
+}
+
+// clear turns off the pixels in the given bitplanes.
+func (e *Emulator) clear(planes byte) {
+	for i := range e.display {
+		e.display[i] &^= planes
+	}
+	e.markAllDirty()
+}
+
+// planes returns the bitplanes that drawing, clearing and scrolling apply to.
+// Only XO-CHIP has more than one plane.
+func (e *Emulator) planes() byte {
+	if e.quirks.Variant < VariantXOCHIP {
+		return 1
+	}
+	return e.plane
+}
+
+// setHires switches between the low and high resolution modes, clearing the
+// display.
+func (e *Emulator) setHires(hires bool) {
+	e.hires = hires
+	e.clear(0xFF)
+}
+
+// scroll moves the contents of the selected bitplanes dx pixels right and dy
+// pixels down. Pixels scrolled in from the edges are unlit.
+func (e *Emulator) scroll(dx, dy int) {
+	w, h := e.resolution()
+	planes := e.planes()
+	var buf [HiresDisplayWidth * HiresDisplayHeight]byte
+	for y := 0; y < h; y++ {
+		sy := y - dy
+		for x := 0; x < w; x++ {
+			p := e.display[y*w+x] &^ planes
+			sx := x - dx
+			if sy >= 0 && sy < h && sx >= 0 && sx < w {
+				p |= e.display[sy*w+sx] & planes
+			}
+			buf[y*w+x] = p
+		}
+	}
+	e.display = buf
+	e.markAllDirty()
+}
+
+// draw XORs the n-byte sprite at mem[I] onto the display at (x, y). On
+// SUPER-CHIP a sprite with n = 0 is a 16x16 sprite of 32 bytes. The starting
+// coordinates always wrap around the display; pixels that run off the right
+// or bottom edge are clipped unless the WrapSprites quirk is set. On XO-CHIP
+// the sprite is drawn into each selected bitplane in turn, with the data for
+// each plane following that of the previous one.
+//
+// VF is set to 1 if any lit pixel was turned off, and 0 otherwise. In the
+// SUPER-CHIP high resolution mode VF is instead set to the number of sprite
+// rows that turned a pixel off or were clipped at the bottom of the display.
+func (e *Emulator) draw(x, y, n byte) {
+	w, h := e.resolution()
+	rows, cols := int(n), 8
+	if n == 0 && e.quirks.Variant >= VariantSCHIP {
+		rows, cols = 16, 16
+	}
+	bytesPerRow := cols / 8
+	countRows := e.hires && e.quirks.Variant == VariantSCHIP
+	x0 := int(x) % w
+	y0 := int(y) % h
+	addr := int(e.i)
+	collisions := 0
+	dirty := image.Rectangle{}
+	for plane := byte(1); plane <= 2; plane <<= 1 {
+		if e.planes()&plane == 0 {
+			continue
+		}
+		for row := 0; row < rows; row++ {
+			py := y0 + row
+			if py >= h {
+				if !e.quirks.WrapSprites {
+					if countRows {
+						collisions += rows - row
+					}
+					break
+				}
+				py %= h
+			}
+			hit := false
+			for col := 0; col < cols; col++ {
+				px := x0 + col
+				if px >= w {
+					if !e.quirks.WrapSprites {
+						break
+					}
+					px %= w
+				}
+				b := e.mem[(addr+row*bytesPerRow+col/8)%e.memSize()]
+				if b&(0x80>>uint(col%8)) == 0 {
+					continue
+				}
+				p := py*w + px
+				if e.display[p]&plane != 0 {
+					hit = true
+				}
+				e.display[p] ^= plane
+				dirty = dirty.Union(image.Rect(px, py, px+1, py+1))
+			}
+			if hit {
+				collisions++
+			}
+		}
+		addr += rows * bytesPerRow
+	}
+	e.markDirty(dirty)
+	if !countRows && collisions > 0 {
+		collisions = 1
+	}
+	e.v[0xF] = byte(collisions)
+}
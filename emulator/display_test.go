@@ -0,0 +1,259 @@
+package emulator
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestHiresSwitch(t *testing.T) {
+	e := NewEmulator(QuirksSCHIP)
+
+	e.WriteOpcode(0x00FF, 0x200) // HIGH
+	e.WriteOpcode(0x00FE, 0x202) // LOW
+	e.display[0] = 1
+
+	e.Step()
+	if w, h := e.Resolution(); w != HiresDisplayWidth || h != HiresDisplayHeight {
+		t.Errorf("Resolution() = %d, %d, expected %d, %d", w, h, HiresDisplayWidth, HiresDisplayHeight)
+	}
+	if f := e.Frame(); len(f.Pixels) != HiresDisplayWidth*HiresDisplayHeight || f.Pixels[0] != 0 {
+		t.Errorf("Frame() has %d pixels, expected a clear %d", len(f.Pixels), HiresDisplayWidth*HiresDisplayHeight)
+	}
+
+	e.Step()
+	if w, h := e.Resolution(); w != DisplayWidth || h != DisplayHeight {
+		t.Errorf("Resolution() = %d, %d, expected %d, %d", w, h, DisplayWidth, DisplayHeight)
+	}
+}
+
+// Test that the SUPER-CHIP instructions are not decoded on CHIP-8.
+func TestSCHIPInstructionsDisabled(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	e.WriteOpcode(0x00FF, 0x200) // SYS 0x0FF
+	e.WriteOpcode(0xF030, 0x202)
+
+	if _, err := e.Step(); err != nil {
+		t.Errorf("Step() = %v, expected nil", err)
+	}
+	if w, _ := e.Resolution(); w != DisplayWidth {
+		t.Errorf("width = %d, expected %d", w, DisplayWidth)
+	}
+	if _, err := e.Step(); !errors.Is(err, InvalidOpcode) {
+		t.Errorf("Step() = %v, expected %v", err, InvalidOpcode)
+	}
+}
+
+func TestScroll(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode uint16
+		x, y   int
+	}{
+		{"down", 0x00C3, 10, 13},
+		{"right", 0x00FB, 14, 10},
+		{"left", 0x00FC, 6, 10},
+	}
+	for _, tt := range tests {
+		for _, hires := range []bool{false, true} {
+			e := NewEmulator(QuirksSCHIP)
+			e.hires = hires
+			w, _ := e.Resolution()
+			e.display[10*w+10] = 1
+			e.WriteOpcode(tt.opcode, 0x200)
+
+			e.Step()
+
+			f := e.Frame()
+			if f.At(tt.x, tt.y) != 1 {
+				t.Errorf("%s (hires %v): pixel(%d,%d) = 0, expected 1", tt.name, hires, tt.x, tt.y)
+			}
+			if f.At(10, 10) != 0 {
+				t.Errorf("%s (hires %v): pixel(10,10) = 1, expected 0", tt.name, hires)
+			}
+		}
+	}
+}
+
+// Test that pixels scrolled off the edge of the display are lost.
+func TestScrollOffEdge(t *testing.T) {
+	e := NewEmulator(QuirksSCHIP)
+	e.display[DisplayWidth-1] = 1
+	e.WriteOpcode(0x00FB, 0x200)
+	e.WriteOpcode(0x00FC, 0x202)
+
+	e.Step()
+	e.Step()
+
+	for i, p := range e.Framebuffer() {
+		if p != 0 {
+			t.Fatalf("pixel %d = %d, expected 0", i, p)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	e := NewEmulator(QuirksSCHIP)
+	e.WriteOpcode(0x00FD, 0x200)
+
+	if _, err := e.Step(); err != ErrHalted {
+		t.Errorf("Step() = %v, expected %v", err, ErrHalted)
+	}
+	if e.pc != 0x200 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x200)
+	}
+}
+
+func TestDrw16x16(t *testing.T) {
+	e := NewEmulator(QuirksSCHIP)
+	e.hires = true
+	sprite := make([]byte, 32)
+	for i := range sprite {
+		sprite[i] = 0x80
+	}
+	e.Write(0x300, sprite)
+	e.i = 0x300
+	e.v[1] = 4
+	e.v[2] = 4
+	e.WriteOpcode(0xD120, 0x200)
+
+	e.Step()
+
+	f := e.Frame()
+	n := 0
+	for _, p := range f.Pixels {
+		n += int(p)
+	}
+	if n != 32 {
+		t.Errorf("lit pixels = %d, expected %d", n, 32)
+	}
+	for y := 4; y < 20; y++ {
+		if f.At(4, y) != 1 || f.At(12, y) != 1 {
+			t.Errorf("row %d not drawn", y)
+		}
+	}
+}
+
+// Test that hires SUPER-CHIP drawing counts colliding and clipped rows in VF.
+func TestDrwRowCollisions(t *testing.T) {
+	e := NewEmulator(QuirksSCHIP)
+	e.hires = true
+	e.Write(0x300, []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	e.i = 0x300
+	e.v[1] = 0
+	e.v[2] = 0
+	e.v[3] = HiresDisplayHeight - 2
+	e.WriteOpcode(0xD123, 0x200)
+	e.WriteOpcode(0xD124, 0x202)
+	e.WriteOpcode(0xD134, 0x204)
+
+	e.Step()
+	if e.v[0xF] != 0 {
+		t.Errorf("VF = %d, expected %d", e.v[0xF], 0)
+	}
+	e.Step()
+	if e.v[0xF] != 3 {
+		t.Errorf("VF = %d, expected %d", e.v[0xF], 3)
+	}
+	e.Step()
+	if e.v[0xF] != 2 {
+		t.Errorf("VF = %d, expected %d", e.v[0xF], 2)
+	}
+}
+
+func TestLdHFVx(t *testing.T) {
+	e := NewEmulator(QuirksSCHIP)
+	e.v[4] = 0x07
+	e.WriteOpcode(0xF430, 0x200)
+
+	e.Step()
+
+	exp := uint16(LargeFontAddress + 7*LargeFontGlyphSize)
+	if e.i != exp {
+		t.Errorf("I = %#04x, expected %#04x", e.i, exp)
+	}
+	if e.mem[e.i] != FontSCHIP.Large[7*LargeFontGlyphSize] {
+		t.Errorf("mem[I] = %#02x, expected %#02x", e.mem[e.i], FontSCHIP.Large[7*LargeFontGlyphSize])
+	}
+}
+
+// displayRecorder is a Display that records the updates it receives.
+type displayRecorder struct {
+	frames []Frame
+	dirty  []image.Rectangle
+}
+
+func (r *displayRecorder) Update(f Frame, dirty image.Rectangle) {
+	r.frames = append(r.frames, f)
+	r.dirty = append(r.dirty, dirty)
+}
+
+func TestDisplayUpdates(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	rec := &displayRecorder{}
+	e.AddDisplay(rec)
+
+	e.Write(0x300, []byte{0xC0, 0x40})
+	e.i = 0x300
+	e.v[1] = 10
+	e.v[2] = 5
+	e.WriteOpcode(0xD122, 0x200) // DRW V1,V2,2
+	e.WriteOpcode(0x7101, 0x202) // ADD V1,1
+	e.WriteOpcode(0x00E0, 0x204) // CLS
+	for i := 0; i < 3; i++ {
+		e.Step()
+	}
+
+	exp := []image.Rectangle{
+		image.Rect(0, 0, DisplayWidth, DisplayHeight),
+		image.Rect(10, 5, 12, 7),
+		image.Rect(0, 0, DisplayWidth, DisplayHeight),
+	}
+	if len(rec.dirty) != len(exp) {
+		t.Fatalf("dirty = %v, expected %v", rec.dirty, exp)
+	}
+	for i := range exp {
+		if rec.dirty[i] != exp[i] {
+			t.Errorf("dirty[%d] = %v, expected %v", i, rec.dirty[i], exp[i])
+		}
+	}
+	if rec.frames[1].At(10, 5) != 1 || rec.frames[1].At(11, 6) != 1 {
+		t.Errorf("frame after DRW does not contain the sprite")
+	}
+}
+
+// Test that every display is notified, and removed displays no longer are.
+func TestMultipleDisplays(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	a, b := &displayRecorder{}, &displayRecorder{}
+	e.AddDisplay(a)
+	e.AddDisplay(b)
+
+	e.WriteOpcode(0x00E0, 0x200)
+	e.WriteOpcode(0x00E0, 0x202)
+	e.Step()
+	e.RemoveDisplay(a)
+	e.Step()
+
+	if len(a.frames) != 2 || len(b.frames) != 3 {
+		t.Errorf("updates = %d, %d, expected 2, 3", len(a.frames), len(b.frames))
+	}
+}
+
+// Test that wrapped sprites report a dirty region covering both edges.
+func TestDisplayDirtyWrap(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	var dirty image.Rectangle
+	e.AddDisplay(DisplayFunc(func(f Frame, r image.Rectangle) { dirty = r }))
+
+	e.Write(0x300, []byte{0xFF})
+	e.i = 0x300
+	e.v[1] = DisplayWidth - 4
+	e.WriteOpcode(0xD101, 0x200)
+	e.Step()
+
+	if exp := image.Rect(0, 0, DisplayWidth, 1); dirty != exp {
+		t.Errorf("dirty = %v, expected %v", dirty, exp)
+	}
+}
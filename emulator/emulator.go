@@ -2,275 +2,571 @@ package emulator
 
 import (
 	"fmt"
+	"image"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	// TimerFrequency holds the frequency of the sound and timer clocks (60hz).
-	TimerFrequency = time.Second / 60
+	TimerFrequency = time.Second / TimerFrequencyHz
+
+	// TimerFrequencyHz holds the frequency of the sound and timer clocks in
+	// ticks per second.
+	TimerFrequencyHz = 60
 
 	// MemorySize holds the amount of RAM available in the emulator.
 	MemorySize = 4096
 
+	// XOCHIPMemorySize holds the amount of RAM available in XO-CHIP mode.
+	XOCHIPMemorySize = 0x10000
+
 	// DisplayHeight holds the number of lines available in the display.
 	DisplayHeight = 32
 
 	// DisplayWidth holds the number of columns available in the display.
 	DisplayWidth = 64
 
+	// HiresDisplayHeight holds the number of lines in the SUPER-CHIP high
+	// resolution mode.
+	HiresDisplayHeight = 64
+
+	// HiresDisplayWidth holds the number of columns in the SUPER-CHIP high
+	// resolution mode.
+	HiresDisplayWidth = 128
+
 	// Registers holds the number of v available in the Emulator.
 	Registers = 16
 
 	// StackSize holds the size of the stack in the Emulator (max call depth).
 	StackSize = 16
+
+	// Keys holds the number of keys on the hexadecimal keypad.
+	Keys = 16
 )
 
 // Emulator represents an instance of the Chip8 emulator.
 type Emulator struct {
-	mem       [MemorySize]byte
-	display   [DisplayWidth * DisplayHeight]byte
-	v         [Registers]byte
-	stack     [StackSize]uint16
-	pc        uint16
-	i         uint16
-	sp        byte
-	st        byte
-	dt        byte
-	timerChan chan bool
+	mem        [XOCHIPMemorySize]byte
+	display    [HiresDisplayWidth * HiresDisplayHeight]byte
+	hires      bool
+	plane      byte
+	dirty      image.Rectangle
+	displays   []Display
+	v          [Registers]byte
+	stack      [StackSize]uint16
+	pc         uint16
+	i          uint16
+	sp         byte
+	st         byte
+	dt         byte
+	keys       [Keys]bool
+	kb         Keyboard
+	held       bool
+	heldKey    byte
+	quirks     Quirks
+	vblank     bool
+	font       FontSet
+	rnd        *rand.Rand
+	sound      func(on bool)
+	pattern    [PatternSize]byte
+	pitch      byte
+	audio      AudioSink
+	sampleRate int
+	sampleDebt int
+	samples    []int16
+	gen        PatternGenerator
+	video      FrameSink
+	tracer     Tracer
+	cache      []Instruction
+	noCache    bool
+	recompile  bool
+	blocks     []*block
+	compiled   []bool
+	interpret  []bool
+	tone       SquareWave
+	volume     float64
+	speed      int
+	start      uint16
+	rom        ROMInfo
+	flags      [FlagRegisters]byte
+	flagStore  FlagStore
+	err        error
+	policy     FaultPolicy
+	trap       func(*Fault) error
+	mu         sync.Mutex
 }
 
-// NewEmulator creates a new Emulator.
-func NewEmulator() *Emulator {
-	return &Emulator{
-		timerChan: nil,
-	}
+// State holds a snapshot of the CPU registers.
+type State struct {
+	V     [Registers]byte
+	I     uint16
+	PC    uint16
+	SP    byte
+	DT    byte
+	ST    byte
+	Stack [StackSize]uint16
 }
 
-func startTicker(d time.Duration, f func()) chan bool {
-	done := make(chan bool, 1)
-	go func() {
-		ticker := time.NewTicker(d)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				f()
-			case <-done:
-				return
-			}
-		}
-	}()
-	return done
+// String formats the registers on two lines, in the style of a monitor
+// register dump.
+func (s State) String() string {
+	var b strings.Builder
+	for r, v := range s.V {
+		if r > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "V%1X=%02X", r, v)
+	}
+	fmt.Fprintf(&b, "\nPC=%04X I=%04X SP=%02X DT=%02X ST=%02X", s.PC, s.I, s.SP, s.DT, s.ST)
+	return b.String()
 }
 
-// Start starts execution of the emulator.
-func (e *Emulator) Start() {
-	// TODO(markcol): Enable the timer
-	// e.startTimer()
+// NewEmulator creates a new Emulator that behaves according to q.
+func NewEmulator(q Quirks) *Emulator {
+	e := &Emulator{
+		quirks:  q,
+		pc:      ProgramAddress,
+		start:   ProgramAddress,
+		plane:   1,
+		pattern: defaultPattern,
+		pitch:   DefaultPitch,
+		volume:  DefaultVolume,
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	switch {
+	case q.Variant >= VariantXOCHIP:
+		e.SetFont(FontXOCHIP)
+	case q.Variant >= VariantSCHIP:
+		e.SetFont(FontSCHIP)
+	default:
+		e.SetFont(FontStandard)
+	}
+	return e
 }
 
-// Stop stops execution of the emulator.
-func (e *Emulator) Stop() {
-	e.stopTimer()
+// runCode fetches, decodes and executes the instruction at the pc.
+func (e *Emulator) runCode() error {
+	in := e.fetch()
+	if e.tracer != nil {
+		e.tracer.Trace(e.pc-2, in)
+	}
+	return e.execute(in)
 }
 
-func (e *Emulator) runCode() {
-	opcode := e.GetOpcode()
-	switch {
-	case opcode == 0x00E0: // CLS
-		e.ClearDisplay()
-	case opcode == 0x00EE: // RET
-		e.ret()
-	case opcode&0xF000 == 0x1000: // JP
-		e.pc = opcode & 0x0FFF
-	case opcode&0xF000 == 0x2000: // CALL
-		e.call(opcode & 0x0FFF)
-	case opcode&0xF000 == 0x3000: // SE Vx,byte
-		r := (opcode & 0x0F00) >> 8
-		if e.v[r] == byte(opcode) {
-			e.pc += 2
-		}
-	case opcode&0xF000 == 0x4000: // SNE Vx,byte
-		r := (opcode & 0x0F00) >> 8
-		if e.v[r] != byte(opcode) {
-			e.pc += 2
-		}
-	case opcode&0xF00F == 0x5000: // SNE Vx,byte
-		x := (opcode & 0x0F00) >> 8
-		y := (opcode & 0x00F0) >> 4
+// execute executes a decoded instruction. The pc has already been advanced
+// past its opcode.
+func (e *Emulator) execute(in Instruction) error {
+	opcode := in.Opcode
+	x, y := in.X, in.Y
+	switch in.Op {
+	case OpCls:
+		e.clear(e.planes())
+	case OpRet:
+		return e.ret(opcode)
+	case OpScrollDown:
+		e.scroll(0, int(in.N))
+	case OpScrollUp:
+		e.scroll(0, -int(in.N))
+	case OpScrollRight:
+		e.scroll(4, 0)
+	case OpScrollLeft:
+		e.scroll(-4, 0)
+	case OpExit:
+		e.pc -= 2
+		return ErrHalted
+	case OpLores:
+		e.setHires(false)
+	case OpHires:
+		e.setHires(true)
+	case OpSys:
+		// Machine code routines of the host CPU are not supported; modern
+		// interpreters ignore this instruction.
+	case OpJump:
+		if in.NNN == e.pc-2 {
+			// A jump to itself can never be left, so the program is done.
+			e.pc -= 2
+			return ErrHalted
+		}
+		e.pc = in.NNN
+	case OpCall:
+		return e.call(opcode)
+	case OpSkipEqByte:
+		if e.v[x] == in.KK {
+			e.skip()
+		}
+	case OpSkipNeByte:
+		if e.v[x] != in.KK {
+			e.skip()
+		}
+	case OpSkipEqReg:
 		if e.v[x] == e.v[y] {
-			e.pc += 2
-		}
-	case opcode&0xF000 == 0x6000: // LD Vx,byte
-		r := (opcode & 0x0F00) >> 8
-		e.v[r] = byte(opcode)
-	case opcode&0xF000 == 0x7000: // ADD Vx,byte
-		r := (opcode & 0x0F00) >> 8
-		e.v[r] += byte(opcode)
-	case opcode&0xF00F == 0x8000: // LD Vx,Vy
-		x := (opcode & 0x0F00) >> 8
-		y := (opcode & 0x00F0) >> 4
+			e.skip()
+		}
+	case OpSaveRange:
+		if int(e.i)+int(absDiff(uint16(x), uint16(y))) >= e.memSize() {
+			return e.fault(AddressOutOfRange, opcode)
+		}
+		for k, r := range registerRange(uint16(x), uint16(y)) {
+			e.mem[int(e.i)+k] = e.v[r]
+		}
+		e.invalidate(int(e.i), int(absDiff(uint16(x), uint16(y)))+1)
+	case OpLoadRange:
+		if int(e.i)+int(absDiff(uint16(x), uint16(y))) >= e.memSize() {
+			return e.fault(AddressOutOfRange, opcode)
+		}
+		for k, r := range registerRange(uint16(x), uint16(y)) {
+			e.v[r] = e.mem[int(e.i)+k]
+		}
+	case OpLoadByte:
+		e.v[x] = in.KK
+	case OpAddByte:
+		e.v[x] += in.KK
+	case OpMove:
 		e.v[x] = e.v[y]
-	case opcode&0xF00F == 0x8001: // OR Vx,Vy
-		x := (opcode & 0x0F00) >> 8
-		y := (opcode & 0x00F0) >> 4
+	case OpOr:
 		e.v[x] |= e.v[y]
-	case opcode&0xF00F == 0x8002: // AND Vx,Vy
-		x := (opcode & 0x0F00) >> 8
-		y := (opcode & 0x00F0) >> 4
+		if e.quirks.ResetVF {
+			e.v[0xF] = 0
+		}
+	case OpAnd:
 		e.v[x] &= e.v[y]
-	case opcode&0xF00F == 0x8003: // XOR Vx,Vy
-		x := (opcode & 0x0F00) >> 8
-		y := (opcode & 0x00F0) >> 4
+		if e.quirks.ResetVF {
+			e.v[0xF] = 0
+		}
+	case OpXor:
 		e.v[x] ^= e.v[y]
-	case opcode&0xF00F == 0x8004: // ADD Vx,Vy
-		x := (opcode & 0x0F00) >> 8
-		y := (opcode & 0x00F0) >> 4
-		exp := uint16(e.v[x]) + uint16(e.v[y])
-		e.v[x] = byte(exp)
-		if exp > 0x00FF {
+		if e.quirks.ResetVF {
+			e.v[0xF] = 0
+		}
+	case OpAdd:
+		sum := uint16(e.v[x]) + uint16(e.v[y])
+		e.v[x] = byte(sum)
+		if sum > 0x00FF {
 			e.v[0xF] = 1
 		} else {
 			e.v[0xF] = 0
 		}
-	case opcode&0xF000 == 0xA000: // LD I,addr
-		addr := opcode & 0x0FFF
-		e.i = addr
-	case opcode&0xF0FF == 0xF007: // LD ST,Vx
-		r := (opcode & 0x0F00) >> 8
-		e.v[r] = e.dt
-	case opcode&0xF0FF == 0xF018: // LD ST,Vx
-		r := (opcode & 0x0F00) >> 8
-		e.st = e.v[r]
-	case opcode&0xF0FF == 0xF01E: // ADD I,Vx
-		r := (opcode & 0x0F00) >> 8
-		e.i += uint16(e.v[r])
-	case opcode&0xF0FF == 0xF055: // LD [I],Vx
-		max := (opcode & 0x0F00) >> 8
-		if e.i+max > uint16(len(e.mem)) {
-			panic("Address out of range")
-		}
-		for i := uint16(0); i < max; i++ {
-			e.mem[e.i+i] = e.v[i]
-		}
-	case opcode&0xF0FF == 0xF065: // LD Vx,[I]
-		max := (opcode & 0x0F00) >> 8
-		if e.i+max > uint16(len(e.mem)) {
-			panic("Address out of range")
-		}
-		for i := uint16(0); i < max; i++ {
-			e.v[i] = e.mem[e.i+i]
+	case OpSub:
+		flag := byte(0)
+		if e.v[x] >= e.v[y] {
+			flag = 1
+		}
+		e.v[x] -= e.v[y]
+		e.v[0xF] = flag
+	case OpShr:
+		if !e.quirks.ShiftVy {
+			y = x
+		}
+		flag := e.v[y] & 0x01
+		e.v[x] = e.v[y] >> 1
+		e.v[0xF] = flag
+	case OpSubn:
+		flag := byte(0)
+		if e.v[y] >= e.v[x] {
+			flag = 1
+		}
+		e.v[x] = e.v[y] - e.v[x]
+		e.v[0xF] = flag
+	case OpShl:
+		if !e.quirks.ShiftVy {
+			y = x
+		}
+		flag := e.v[y] >> 7
+		e.v[x] = e.v[y] << 1
+		e.v[0xF] = flag
+	case OpSkipNeReg:
+		if e.v[x] != e.v[y] {
+			e.skip()
+		}
+	case OpLoadI:
+		e.i = in.NNN
+	case OpJumpV0:
+		r := byte(0)
+		if e.quirks.JumpVx {
+			r = byte(in.NNN >> 8)
+		}
+		e.pc = (in.NNN + uint16(e.v[r])) & 0x0FFF
+	case OpRand:
+		e.v[x] = e.random() & in.KK
+	case OpDraw:
+		e.draw(e.v[x], e.v[y], in.N)
+		if e.quirks.DisplayWait {
+			e.vblank = true
+		}
+	case OpSkipKey:
+		if e.keys[e.v[x]&0x0F] {
+			e.skip()
+		}
+	case OpSkipNotKey:
+		if !e.keys[e.v[x]&0x0F] {
+			e.skip()
+		}
+	case OpLoadILong:
+		e.i = in.NNN
+		e.pc += 2
+	case OpAudio:
+		if int(e.i)+PatternSize > e.memSize() {
+			return e.fault(AddressOutOfRange, opcode)
+		}
+		copy(e.pattern[:], e.mem[e.i:])
+	case OpPlane:
+		e.plane = x & 0x03
+	case OpGetDelay:
+		e.v[x] = e.dt
+	case OpWaitKey:
+		key, ok := e.waitKey()
+		if !ok {
+			// Re-execute this instruction until a key is released.
+			e.pc -= 2
+			break
+		}
+		e.v[x] = key
+	case OpSetDelay:
+		e.dt = e.v[x]
+	case OpSetSound:
+		e.setSoundTimer(e.v[x])
+	case OpAddI:
+		e.i += uint16(e.v[x])
+	case OpFont:
+		e.i = FontAddress + uint16(e.v[x]&0x0F)*FontGlyphSize
+	case OpBigFont:
+		e.i = LargeFontAddress + uint16(e.v[x]&0x0F)*LargeFontGlyphSize
+	case OpSaveFlags:
+		return e.saveFlags(uint16(x))
+	case OpLoadFlags:
+		e.restoreFlags(uint16(x))
+	case OpPitch:
+		e.pitch = e.v[x]
+	case OpBCD:
+		if int(e.i)+2 >= e.memSize() {
+			return e.fault(AddressOutOfRange, opcode)
+		}
+		e.mem[e.i] = e.v[x] / 100
+		e.mem[e.i+1] = e.v[x] / 10 % 10
+		e.mem[e.i+2] = e.v[x] % 10
+		e.invalidate(int(e.i), 3)
+	case OpSave:
+		if int(e.i)+int(x) >= e.memSize() {
+			return e.fault(AddressOutOfRange, opcode)
+		}
+		for r := byte(0); r <= x; r++ {
+			e.mem[int(e.i)+int(r)] = e.v[r]
+		}
+		e.invalidate(int(e.i), int(x)+1)
+		if e.quirks.IncrementI {
+			e.i += uint16(x) + 1
+		}
+	case OpLoad:
+		if int(e.i)+int(x) >= e.memSize() {
+			return e.fault(AddressOutOfRange, opcode)
+		}
+		for r := byte(0); r <= x; r++ {
+			e.v[r] = e.mem[int(e.i)+int(r)]
+		}
+		if e.quirks.IncrementI {
+			e.i += uint16(x) + 1
 		}
 	default:
+		return e.fault(InvalidOpcode, opcode)
 	}
+	return nil
+}
 
+// State returns a snapshot of the CPU registers.
+func (e *Emulator) State() State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return State{
+		V:     e.v,
+		I:     e.i,
+		PC:    e.pc,
+		SP:    e.sp,
+		DT:    e.dt,
+		ST:    e.st,
+		Stack: e.stack,
+	}
 }
 
 // WriteOpcode writes an opcode at the given address
-func (e *Emulator) WriteOpcode(opcode uint16, addr uint16) {
-	if (addr + 1) > MemorySize {
-		panic("Address out of range")
+func (e *Emulator) WriteOpcode(opcode uint16, addr uint16) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if int(addr)+1 >= e.memSize() {
+		return fmt.Errorf("write opcode at %#04x: %w", addr, AddressOutOfRange)
 	}
 	e.mem[addr] = byte(opcode >> 8)
 	e.mem[addr+1] = byte(opcode)
+	e.invalidate(int(addr), 2)
+	return nil
 }
 
 // ReadOpcode reads an opcode from the given address
-func (e *Emulator) ReadOpcode(addr uint16) uint16 {
-	if (addr + 1) > MemorySize {
-		panic("Address out of range")
+func (e *Emulator) ReadOpcode(addr uint16) (uint16, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if int(addr)+1 >= e.memSize() {
+		return 0, fmt.Errorf("read opcode at %#04x: %w", addr, AddressOutOfRange)
+	}
+	return e.opcodeAt(addr), nil
+}
+
+// opcodeAt returns the two-byte opcode at mem[addr] << 8 | mem[addr+1].
+func (e *Emulator) opcodeAt(addr uint16) uint16 {
+	n := e.memSize()
+	return uint16(e.mem[int(addr)%n])<<8 | uint16(e.mem[(int(addr)+1)%n])
+}
+
+// memSize returns the amount of memory addressable by the program.
+func (e *Emulator) memSize() int {
+	if e.quirks.Variant >= VariantXOCHIP {
+		return XOCHIPMemorySize
+	}
+	return MemorySize
+}
+
+// skip skips over the next instruction. On XO-CHIP this includes both words
+// of a long load.
+func (e *Emulator) skip() {
+	if e.quirks.Variant >= VariantXOCHIP && e.opcodeAt(e.pc) == 0xF000 {
+		e.pc += 2
+	}
+	e.pc += 2
+}
+
+// registerRange returns the register numbers from x to y inclusive, in
+// descending order if y is less than x.
+func registerRange(x, y uint16) []uint16 {
+	regs := make([]uint16, 0, absDiff(x, y)+1)
+	for r := x; ; {
+		regs = append(regs, r)
+		if r == y {
+			return regs
+		}
+		if x < y {
+			r++
+		} else {
+			r--
+		}
+	}
+}
+
+func absDiff(a, b uint16) uint16 {
+	if a > b {
+		return a - b
 	}
-	op := uint16(e.mem[addr])<<8 | uint16(e.mem[addr+1])
-	//op |=  uint16(e.mem[addr + 1]) & 0x00FF
-	return op
+	return b - a
 }
 
-// Beep sounds the speaker.
+// Beep sounds the buzzer for a single timer period if it is silent. A tone
+// already sounding is left to run its course. The sound is delivered to the
+// OnSound callback and the audio sink.
 func (e *Emulator) Beep() {
-	fmt.Printf("Beep")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.st == 0 {
+		e.setSoundTimer(1)
+	}
+}
+
+// OnSound registers f to be called whenever the speaker is switched on or
+// off by the sound timer. The callback runs on the goroutine executing the
+// emulator and must neither block nor call back into the emulator. Passing
+// nil removes the callback.
+func (e *Emulator) OnSound(f func(on bool)) {
+	e.mu.Lock()
+	e.sound = f
+	e.mu.Unlock()
 }
 
 // Write sets the memory at addr..address+len(bytes) to the value of the byte slice.
 func (e *Emulator) Write(addr uint16, bytes []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	beg := int(addr)
-	if beg >= MemorySize {
+	if beg >= e.memSize() {
 		return
 	}
 	max := int(addr) + len(bytes)
-	if max >= MemorySize {
-		max = MemorySize
-	}
-	for i := 0; i < len(bytes); i++ {
-		e.mem[addr+uint16(i)] = bytes[i]
+	if max >= e.memSize() {
+		max = e.memSize()
 	}
+	copy(e.mem[beg:max], bytes)
+	e.invalidate(beg, max-beg)
 }
 
 // Read returns a slice of bytes from memory.
 func (e *Emulator) Read(addr uint16, l uint) []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	start := int(addr)
 	end := int(addr) + int(l)
-	if start >= MemorySize {
+	if start >= e.memSize() {
 		return []byte{}
 	}
-	if end > MemorySize {
-		end = MemorySize
+	if end > e.memSize() {
+		end = e.memSize()
 	}
 	bytes := make([]byte, end-start)
-	for i := 0; i < int(l); i++ {
-		bytes[i] = e.mem[start+i]
-	}
+	copy(bytes, e.mem[start:end])
 	return bytes
 }
 
 // GetOpcode returns the two-byte opcode at mem[pc] << 8 | mem[pc+1] and advances the pc.
 func (e *Emulator) GetOpcode() uint16 {
-	opcode := uint16(e.mem[e.pc]) << 8
-	opcode |= uint16(e.mem[e.pc+1])
+	opcode := e.opcodeAt(e.pc)
 	e.pc += 2
 	return opcode
 }
 
-// ClearDisplay sets the display to all 0s.
-func (e *Emulator) ClearDisplay() {
-	for i := 0; i < DisplayHeight*DisplayWidth; i++ {
-		e.display[i] = 0
+// random returns a random byte.
+func (e *Emulator) random() byte {
+	if e.rnd == nil {
+		return byte(rand.Intn(256))
 	}
+	return byte(e.rnd.Intn(256))
 }
 
-func (e *Emulator) call(a uint16) {
+func (e *Emulator) call(opcode uint16) error {
 	if e.sp >= StackSize {
-		panic("Emulator stack overflow")
+		return e.fault(StackOverflow, opcode)
 	}
-	if a >= MemorySize {
-		panic("Emulator address out of bounds")
-	}
-	e.sp++
 	e.stack[e.sp] = e.pc
-	e.pc = a
+	e.sp++
+	e.pc = opcode & 0x0FFF
+	return nil
 }
 
-func (e *Emulator) ret() {
+func (e *Emulator) ret(opcode uint16) error {
 	if e.sp == 0 {
-		panic("Emulator stack underflow")
+		return e.fault(StackUnderflow, opcode)
 	}
-	e.pc = e.stack[e.sp]
 	e.sp--
+	e.pc = e.stack[e.sp]
+	return nil
 }
 
-// start the background clock timer
-func (e *Emulator) startTimer() {
-	if e.timerChan == nil {
-		e.timerChan = startTicker(TimerFrequency, e.timerCallback)
+// tick decrements the delay and sound timers by one period.
+func (e *Emulator) tick() {
+	if e.dt > 0 {
+		e.dt--
+	}
+	if e.st > 0 {
+		e.st--
+		if e.st == 0 && e.sound != nil {
+			e.sound(false)
+		}
 	}
 }
 
-// stop the background clock timer
-func (e *Emulator) stopTimer() {
-	close(e.timerChan)
-	// Let the goroutine finish
-	time.Sleep(2 * TimerFrequency)
-	e.timerChan = nil
-}
-
-func (e *Emulator) timerCallback() {
+// setSoundTimer loads the sound timer, switching the speaker on or off if the
+// timer starts or stops running.
+func (e *Emulator) setSoundTimer(v byte) {
+	was := e.st > 0
+	e.st = v
+	if e.sound != nil && was != (v > 0) {
+		e.sound(v > 0)
+	}
 }
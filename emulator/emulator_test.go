@@ -1,6 +1,7 @@
 package emulator
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -46,7 +47,10 @@ func TestReadOpcode(t *testing.T) {
 	opcode := uint16(0x1F7F)
 
 	e.WriteOpcode(opcode, addr)
-	op := e.ReadOpcode(addr)
+	op, err := e.ReadOpcode(addr)
+	if err != nil {
+		t.Fatalf("ReadOpcode(%#04x) = %v, expected nil", addr, err)
+	}
 	if op != opcode {
 		t.Errorf("opcode(%#04x) = %#04x, expected %#04x", addr, op, opcode)
 	}
@@ -124,8 +128,8 @@ func TestRet(t *testing.T) {
 	oldAddr := uint16(0x135 & 0x0FFF)
 
 	e.pc = addr
-	e.sp++
 	e.stack[e.sp] = oldAddr
+	e.sp++
 
 	if e.pc != addr {
 		t.Errorf("PC = %#04x, expected %#04x", e.pc, addr)
@@ -135,8 +139,8 @@ func TestRet(t *testing.T) {
 		t.Errorf("SP = %#02x, expected %#02x", e.sp, 1)
 	}
 
-	if e.stack[e.sp] != oldAddr {
-		t.Errorf("stack[%#02x] = %#04x, expected %#04x", e.sp, oldAddr, e.stack[e.sp])
+	if e.stack[0] != oldAddr {
+		t.Errorf("stack[0] = %#04x, expected %#04x", e.stack[0], oldAddr)
 	}
 
 	e.runCode()
@@ -191,8 +195,35 @@ func TestCall(t *testing.T) {
 	if e.sp != 1 {
 		t.Errorf("SP = %#02x, expected %#02x", e.sp, 1)
 	}
-	if e.stack[e.sp] != oldPc {
-		t.Errorf("stack[%#02x] = %#04x, expected %#04x", e.sp, e.stack[e.sp], oldPc)
+	if e.stack[0] != oldPc {
+		t.Errorf("stack[0] = %#04x, expected %#04x", e.stack[0], oldPc)
+	}
+}
+
+// Test that StackSize calls can be nested and that one more overflows the
+// stack.
+func TestCallDepth(t *testing.T) {
+	e := &Emulator{}
+	for k := uint16(0); k <= StackSize; k++ {
+		e.WriteOpcode(0x2000|(k+1)*2, k*2) // CALL to the next instruction
+	}
+
+	for k := 0; k < StackSize; k++ {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("call %d: Step() = %v, expected nil", k+1, err)
+		}
+	}
+	if e.sp != StackSize {
+		t.Errorf("SP = %#02x, expected %#02x", e.sp, StackSize)
+	}
+	for k := uint16(0); k < StackSize; k++ {
+		if e.stack[k] != (k+1)*2 {
+			t.Errorf("stack[%d] = %#04x, expected %#04x", k, e.stack[k], (k+1)*2)
+		}
+	}
+
+	if _, err := e.Step(); !errors.Is(err, StackOverflow) {
+		t.Errorf("Step() = %v, expected %v", err, StackOverflow)
 	}
 }
 
@@ -618,14 +649,14 @@ func TestLnIVx(t *testing.T) {
 
 	e.runCode()
 
-	// ensure that target area is set to the register value
-	for i := uint16(0); i < uint16(l); i++ {
+	// ensure that target area is set to the register value, V0 through VF
+	for i := uint16(0); i <= uint16(l); i++ {
 		if e.mem[e.i+i] != e.v[i] {
 			t.Errorf("mem[%#04x] = %#2x, expected %#02x", e.i+i, e.mem[e.i+i], regs[i])
 		}
 	}
-	if e.mem[e.i+l] != 0 {
-		t.Errorf("mem[%#04x] = %#2x, expected %#02x", e.i+l, e.mem[e.i+l], 0)
+	if e.mem[e.i+l+1] != 0 {
+		t.Errorf("mem[%#04x] = %#2x, expected %#02x", e.i+l+1, e.mem[e.i+l+1], 0)
 	}
 	// ensure that I still points to the initial address
 	if e.i != addr {
@@ -662,8 +693,8 @@ func TestLdVxI(t *testing.T) {
 
 	e.runCode()
 
-	// ensure that target area is set to the register value
-	for i := uint16(0); i < uint16(l); i++ {
+	// ensure that V0 through Vx are set to the memory values
+	for i := uint16(0); i <= uint16(l); i++ {
 		if e.v[i] != e.mem[e.i+i] {
 			t.Errorf("V%1X = %#2x, expected %#02x", i, e.v[i], e.mem[e.i+i])
 		}
@@ -673,3 +704,336 @@ func TestLdVxI(t *testing.T) {
 		t.Errorf("I = %#04x, expected %#04x", e.i, addr)
 	}
 }
+
+func TestArithmeticVxVy(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode uint16
+		vx     byte
+		vy     byte
+		exp    byte
+		flag   byte
+	}{
+		{"SUB no borrow", 0x8675, 0x20, 0x10, 0x10, 1},
+		{"SUB equal", 0x8675, 0x10, 0x10, 0x00, 1},
+		{"SUB borrow", 0x8675, 0x10, 0x20, 0xF0, 0},
+		{"SHR lsb set", 0x8676, 0x05, 0x00, 0x02, 1},
+		{"SHR lsb clear", 0x8676, 0x04, 0x00, 0x02, 0},
+		{"SUBN no borrow", 0x8677, 0x10, 0x20, 0x10, 1},
+		{"SUBN borrow", 0x8677, 0x20, 0x10, 0xF0, 0},
+		{"SHL msb set", 0x867E, 0x81, 0x00, 0x02, 1},
+		{"SHL msb clear", 0x867E, 0x41, 0x00, 0x82, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Emulator{}
+			e.WriteOpcode(tt.opcode, 0x000)
+			e.v[6] = tt.vx
+			e.v[7] = tt.vy
+
+			e.runCode()
+
+			if e.v[6] != tt.exp {
+				t.Errorf("V6 = %#02x, expected %#02x", e.v[6], tt.exp)
+			}
+			if e.v[0xF] != tt.flag {
+				t.Errorf("VF = %#02x, expected %#02x", e.v[0xF], tt.flag)
+			}
+		})
+	}
+}
+
+// Test that the flag wins when VF is also the destination register.
+func TestArithmeticFlagOverwritesVF(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode uint16
+		vf     byte
+		vy     byte
+		flag   byte
+	}{
+		{"ADD", 0x8F14, 0xFF, 0x01, 1},
+		{"SUB", 0x8F15, 0x01, 0x02, 0},
+		{"SHR", 0x8F16, 0x03, 0x00, 1},
+		{"SUBN", 0x8F17, 0x01, 0x02, 1},
+		{"SHL", 0x8F1E, 0x01, 0x00, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Emulator{}
+			e.WriteOpcode(tt.opcode, 0x000)
+			e.v[0xF] = tt.vf
+			e.v[1] = tt.vy
+
+			e.runCode()
+
+			if e.v[0xF] != tt.flag {
+				t.Errorf("VF = %#02x, expected %#02x", e.v[0xF], tt.flag)
+			}
+		})
+	}
+}
+
+func TestSkipInstructions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode uint16
+		vx     byte
+		vy     byte
+		key    bool
+		skip   bool
+	}{
+		{"SNE Vx,Vy equal", 0x9670, 0x12, 0x12, false, false},
+		{"SNE Vx,Vy not equal", 0x9670, 0x12, 0x13, false, true},
+		{"SKP pressed", 0xE69E, 0x0A, 0x00, true, true},
+		{"SKP not pressed", 0xE69E, 0x0A, 0x00, false, false},
+		{"SKNP pressed", 0xE6A1, 0x0A, 0x00, true, false},
+		{"SKNP not pressed", 0xE6A1, 0x0A, 0x00, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Emulator{}
+			e.WriteOpcode(tt.opcode, 0x000)
+			e.v[6] = tt.vx
+			e.v[7] = tt.vy
+			e.keys[0x0A] = tt.key
+
+			e.runCode()
+
+			epc := uint16(0x0002)
+			if tt.skip {
+				epc = 0x0004
+			}
+			if e.pc != epc {
+				t.Errorf("PC = %#04x, expected %#04x", e.pc, epc)
+			}
+		})
+	}
+}
+
+func TestJpV0(t *testing.T) {
+	e := &Emulator{}
+
+	e.WriteOpcode(0xB300, 0x000)
+	e.v[0] = 0x25
+
+	e.runCode()
+
+	if e.pc != 0x0325 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x0325)
+	}
+}
+
+func TestRndVxByte(t *testing.T) {
+	e := &Emulator{}
+
+	for i := 0; i < 32; i++ {
+		e.pc = 0
+		e.WriteOpcode(0xC60F, 0x000)
+		e.runCode()
+		if e.v[6]&0xF0 != 0 {
+			t.Fatalf("V6 = %#02x, expected mask %#02x", e.v[6], 0x0F)
+		}
+	}
+}
+
+func TestSys(t *testing.T) {
+	e := &Emulator{}
+
+	e.WriteOpcode(0x0123, 0x000)
+
+	e.runCode()
+
+	if e.pc != 0x0002 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x0002)
+	}
+}
+
+func TestLdDtVx(t *testing.T) {
+	e := &Emulator{}
+
+	e.WriteOpcode(0xF615, 0x000)
+	e.v[6] = 0x17
+
+	e.runCode()
+
+	if e.dt != 0x17 {
+		t.Errorf("DT = %#02x, expected %#02x", e.dt, 0x17)
+	}
+}
+
+func TestLdFVx(t *testing.T) {
+	e := &Emulator{}
+
+	e.WriteOpcode(0xF629, 0x000)
+	e.v[6] = 0x0A
+
+	e.runCode()
+
+	exp := uint16(FontAddress + 0x0A*FontGlyphSize)
+	if e.i != exp {
+		t.Errorf("I = %#04x, expected %#04x", e.i, exp)
+	}
+}
+
+func TestLdBVx(t *testing.T) {
+	tests := []struct {
+		val byte
+		exp [3]byte
+	}{
+		{0, [3]byte{0, 0, 0}},
+		{7, [3]byte{0, 0, 7}},
+		{42, [3]byte{0, 4, 2}},
+		{255, [3]byte{2, 5, 5}},
+	}
+	for _, tt := range tests {
+		e := &Emulator{}
+		e.WriteOpcode(0xF633, 0x000)
+		e.v[6] = tt.val
+		e.i = 0x0300
+
+		e.runCode()
+
+		for i := uint16(0); i < 3; i++ {
+			if e.mem[e.i+i] != tt.exp[i] {
+				t.Errorf("BCD(%d): mem[%#04x] = %d, expected %d", tt.val, e.i+i, e.mem[e.i+i], tt.exp[i])
+			}
+		}
+	}
+}
+
+func TestDrw(t *testing.T) {
+	e := &Emulator{}
+
+	e.Write(0x300, []byte{0xF0, 0x90})
+	e.i = 0x300
+	e.v[1] = 2
+	e.v[2] = 3
+	e.WriteOpcode(0xD122, 0x000)
+	e.WriteOpcode(0xD122, 0x002)
+
+	e.runCode()
+
+	if e.v[0xF] != 0 {
+		t.Errorf("VF = %#02x, expected %#02x", e.v[0xF], 0)
+	}
+	for _, p := range [][2]int{{2, 3}, {5, 3}, {2, 4}, {5, 4}} {
+		if e.display[p[1]*DisplayWidth+p[0]] != 1 {
+			t.Errorf("pixel(%d,%d) = 0, expected 1", p[0], p[1])
+		}
+	}
+	if e.display[4*DisplayWidth+3] != 0 {
+		t.Errorf("pixel(%d,%d) = 1, expected 0", 3, 4)
+	}
+
+	// drawing the same sprite again erases it and reports a collision
+	e.runCode()
+
+	if e.v[0xF] != 1 {
+		t.Errorf("VF = %#02x, expected %#02x", e.v[0xF], 1)
+	}
+	for i, p := range e.display {
+		if p != 0 {
+			t.Fatalf("display[%d] = %d, expected 0", i, p)
+		}
+	}
+}
+
+func TestDrwEdges(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap bool
+		x    byte
+		y    byte
+		n    int
+		lit  [][2]int
+	}{
+		{"clip right", false, 62, 0, 4, [][2]int{{62, 0}, {63, 0}, {63, 1}}},
+		{"clip bottom", false, 0, 31, 8, [][2]int{{0, 31}, {7, 31}}},
+		{"wrap right", true, 62, 0, 16, [][2]int{{62, 0}, {63, 0}, {0, 0}, {5, 0}}},
+		{"wrap bottom", true, 0, 31, 16, [][2]int{{0, 31}, {7, 31}, {0, 0}, {7, 0}}},
+		{"start wraps", false, 66, 33, 16, [][2]int{{2, 1}, {9, 1}, {9, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Emulator{}
+			e.quirks.WrapSprites = tt.wrap
+			e.Write(0x300, []byte{0xFF, 0xFF})
+			e.i = 0x300
+			e.v[1] = tt.x
+			e.v[2] = tt.y
+			e.WriteOpcode(0xD122, 0x000)
+
+			e.runCode()
+
+			fb := e.Framebuffer()
+			n := 0
+			for _, p := range fb {
+				n += int(p)
+			}
+			if n != tt.n {
+				t.Errorf("lit pixels = %d, expected %d", n, tt.n)
+			}
+			for _, p := range tt.lit {
+				if fb[p[1]*DisplayWidth+p[0]] != 1 {
+					t.Errorf("pixel(%d,%d) = 0, expected 1", p[0], p[1])
+				}
+			}
+		})
+	}
+}
+
+func TestFramebufferIsCopy(t *testing.T) {
+	e := &Emulator{}
+
+	fb := e.Framebuffer()
+	if len(fb) != DisplayWidth*DisplayHeight {
+		t.Fatalf("len(Framebuffer()) = %d, expected %d", len(fb), DisplayWidth*DisplayHeight)
+	}
+	fb[0] = 1
+	if e.display[0] != 0 {
+		t.Errorf("display[0] = %d, expected 0", e.display[0])
+	}
+}
+
+func TestTick(t *testing.T) {
+	e := &Emulator{}
+
+	var events []bool
+	e.OnSound(func(on bool) { events = append(events, on) })
+	e.dt = 2
+	e.WriteOpcode(0xF618, 0x000)
+	e.v[6] = 2
+
+	e.runCode()
+	for i := 0; i < 3; i++ {
+		e.tick()
+	}
+
+	if e.dt != 0 {
+		t.Errorf("DT = %#02x, expected %#02x", e.dt, 0)
+	}
+	if e.st != 0 {
+		t.Errorf("ST = %#02x, expected %#02x", e.st, 0)
+	}
+	if len(events) != 2 || !events[0] || events[1] {
+		t.Errorf("sound events = %v, expected [true false]", events)
+	}
+}
+
+func TestState(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	e.v[0xA] = 0x5C
+	e.i = 0x0321
+	e.dt = 7
+
+	s := e.State()
+	if s.V[0xA] != 0x5C || s.I != 0x0321 || s.PC != ProgramAddress || s.DT != 7 {
+		t.Errorf("State() = %+v, did not match the registers", s)
+	}
+	exp := "V0=00 V1=00 V2=00 V3=00 V4=00 V5=00 V6=00 V7=00 V8=00 V9=00 VA=5C VB=00 VC=00 VD=00 VE=00 VF=00\nPC=0200 I=0321 SP=00 DT=07 ST=00"
+	if s.String() != exp {
+		t.Errorf("String() = %q, expected %q", s.String(), exp)
+	}
+}
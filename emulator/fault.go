@@ -0,0 +1,119 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+// FaultKind identifies the kind of illegal operation performed by a program.
+// Each kind is also an error, so a fault can be matched with errors.Is.
+type FaultKind int
+
+const (
+	// StackOverflow is raised by a CALL when the stack is full.
+	StackOverflow FaultKind = iota + 1
+
+	// StackUnderflow is raised by a RET when the stack is empty.
+	StackUnderflow
+
+	// AddressOutOfRange is raised when an instruction accesses memory past
+	// the end of the address space.
+	AddressOutOfRange
+
+	// InvalidOpcode is raised when the opcode does not decode to an
+	// instruction.
+	InvalidOpcode
+)
+
+var faultNames = map[FaultKind]string{
+	StackOverflow:     "stack overflow",
+	StackUnderflow:    "stack underflow",
+	AddressOutOfRange: "address out of range",
+	InvalidOpcode:     "invalid opcode",
+}
+
+func (k FaultKind) String() string {
+	if n, ok := faultNames[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("fault(%d)", int(k))
+}
+
+func (k FaultKind) Error() string {
+	return k.String()
+}
+
+// Fault is the error returned when a program performs an illegal operation.
+// It records the machine state at the faulting instruction.
+type Fault struct {
+	Kind   FaultKind
+	PC     uint16
+	Opcode uint16
+	V      [Registers]byte
+	I      uint16
+	SP     byte
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%v at %#04x (opcode %#04x)", f.Kind, f.PC, f.Opcode)
+}
+
+// Is reports whether target is the kind of this fault.
+func (f *Fault) Is(target error) bool {
+	k, ok := target.(FaultKind)
+	return ok && k == f.Kind
+}
+
+// FaultPolicy selects what the emulator does when a program faults.
+type FaultPolicy int
+
+const (
+	// FaultHalt stops the program at the faulting instruction and returns the
+	// fault. This is the default.
+	FaultHalt FaultPolicy = iota
+
+	// FaultIgnore skips the faulting instruction and continues.
+	FaultIgnore
+
+	// FaultTrap calls the trap handler, which decides whether to continue.
+	FaultTrap
+)
+
+// SetFaultPolicy selects how faults are handled. When p is FaultTrap, trap is
+// called with each fault; execution continues after the faulting instruction
+// if it returns nil, and halts with the returned error otherwise.
+func (e *Emulator) SetFaultPolicy(p FaultPolicy, trap func(*Fault) error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policy = p
+	e.trap = trap
+}
+
+// fault returns a fault of the given kind for the instruction just fetched.
+func (e *Emulator) fault(k FaultKind, opcode uint16) *Fault {
+	return &Fault{
+		Kind:   k,
+		PC:     e.pc - 2,
+		Opcode: opcode,
+		V:      e.v,
+		I:      e.i,
+		SP:     e.sp,
+	}
+}
+
+// handleFault applies the fault policy to f, returning the error that stops
+// the program or nil if execution should continue.
+func (e *Emulator) handleFault(f *Fault) error {
+	var err error = f
+	switch e.policy {
+	case FaultIgnore:
+		err = nil
+	case FaultTrap:
+		if e.trap != nil {
+			err = e.trap(f)
+		}
+	}
+	if err != nil {
+		e.pc = f.PC
+	}
+	return err
+}
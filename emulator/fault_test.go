@@ -0,0 +1,110 @@
+package emulator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		opcode uint16
+		setup  func(e *Emulator)
+		kind   FaultKind
+	}{
+		{"stack overflow", 0x2300, func(e *Emulator) { e.sp = StackSize }, StackOverflow},
+		{"stack underflow", 0x00EE, func(e *Emulator) {}, StackUnderflow},
+		{"LD B out of range", 0xF133, func(e *Emulator) { e.i = MemorySize - 2 }, AddressOutOfRange},
+		{"LD [I] out of range", 0xF355, func(e *Emulator) { e.i = MemorySize - 3 }, AddressOutOfRange},
+		{"LD Vx,[I] out of range", 0xF365, func(e *Emulator) { e.i = MemorySize - 3 }, AddressOutOfRange},
+		{"invalid opcode", 0x8008, func(e *Emulator) {}, InvalidOpcode},
+		{"invalid E opcode", 0xE100, func(e *Emulator) {}, InvalidOpcode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Emulator{}
+			e.WriteOpcode(tt.opcode, 0x200)
+			e.pc = 0x200
+			e.v[3] = 0x42
+			tt.setup(e)
+
+			_, err := e.Step()
+
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("Step() = %v, expected %v", err, tt.kind)
+			}
+			var f *Fault
+			if !errors.As(err, &f) {
+				t.Fatalf("Step() = %T, expected *Fault", err)
+			}
+			if f.PC != 0x200 || f.Opcode != tt.opcode || f.V[3] != 0x42 {
+				t.Errorf("fault = %+v, expected PC %#04x, opcode %#04x, V3 %#02x", f, 0x200, tt.opcode, 0x42)
+			}
+			if e.pc != 0x200 {
+				t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x200)
+			}
+
+			// the emulator stays halted on the fault
+			if _, err2 := e.Step(); err2 != err {
+				t.Errorf("Step() = %v, expected %v", err2, err)
+			}
+		})
+	}
+}
+
+func TestFaultIgnore(t *testing.T) {
+	e := &Emulator{}
+	e.SetFaultPolicy(FaultIgnore, nil)
+
+	e.WriteOpcode(0x00EE, 0x000) // RET
+	e.WriteOpcode(0x6017, 0x002) // LD V0,0x17
+
+	if err := e.RunFrame(2); err != nil {
+		t.Fatalf("RunFrame() = %v, expected nil", err)
+	}
+	if e.v[0] != 0x17 {
+		t.Errorf("V0 = %#02x, expected %#02x", e.v[0], 0x17)
+	}
+}
+
+func TestFaultTrap(t *testing.T) {
+	e := &Emulator{}
+	stop := errors.New("stop")
+	var faults []*Fault
+	e.SetFaultPolicy(FaultTrap, func(f *Fault) error {
+		faults = append(faults, f)
+		if len(faults) > 1 {
+			return stop
+		}
+		return nil
+	})
+
+	e.WriteOpcode(0xFFFF, 0x000)
+	e.WriteOpcode(0x00EE, 0x002)
+
+	if err := e.RunFrame(3); err != stop {
+		t.Fatalf("RunFrame() = %v, expected %v", err, stop)
+	}
+	if len(faults) != 2 || faults[0].Kind != InvalidOpcode || faults[1].Kind != StackUnderflow {
+		t.Errorf("faults = %v, expected [%v %v]", faults, InvalidOpcode, StackUnderflow)
+	}
+	if e.pc != 0x002 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x002)
+	}
+}
+
+func TestOpcodeOutOfRange(t *testing.T) {
+	e := &Emulator{}
+
+	if err := e.WriteOpcode(0x1234, MemorySize-1); !errors.Is(err, AddressOutOfRange) {
+		t.Errorf("WriteOpcode() = %v, expected %v", err, AddressOutOfRange)
+	}
+	if _, err := e.ReadOpcode(MemorySize - 1); !errors.Is(err, AddressOutOfRange) {
+		t.Errorf("ReadOpcode() = %v, expected %v", err, AddressOutOfRange)
+	}
+
+	e.pc = MemorySize - 1
+	if _, err := e.Step(); !errors.Is(err, AddressOutOfRange) {
+		t.Errorf("Step() = %v, expected %v", err, AddressOutOfRange)
+	}
+}
@@ -0,0 +1,130 @@
+package emulator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FlagRegisters holds the maximum number of persistent user flags. SUPER-CHIP
+// provides 8 of them, modelled on the HP-48 RPL user flags.
+const FlagRegisters = 16
+
+// FlagStore persists the user flags saved by LD R,Vx. Flags are stored per
+// ROM, keyed by the hex SHA-1 of the ROM image.
+type FlagStore interface {
+	// Load returns the flags saved for key, or nil if there are none.
+	Load(key string) ([]byte, error)
+
+	// Save replaces the flags saved for key.
+	Save(key string, flags []byte) error
+}
+
+// MemoryFlagStore is a FlagStore that keeps flags in memory.
+type MemoryFlagStore struct {
+	mu    sync.Mutex
+	flags map[string][]byte
+}
+
+// NewMemoryFlagStore creates an empty MemoryFlagStore.
+func NewMemoryFlagStore() *MemoryFlagStore {
+	return &MemoryFlagStore{flags: make(map[string][]byte)}
+}
+
+// Load implements FlagStore.
+func (s *MemoryFlagStore) Load(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.flags[key]...), nil
+}
+
+// Save implements FlagStore.
+func (s *MemoryFlagStore) Save(key string, flags []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[key] = append([]byte(nil), flags...)
+	return nil
+}
+
+// FileFlagStore is a FlagStore that keeps the flags for each ROM in a file
+// named <key>.flags in Dir.
+type FileFlagStore struct {
+	Dir string
+}
+
+func (s FileFlagStore) path(key string) string {
+	return filepath.Join(s.Dir, key+".flags")
+}
+
+// Load implements FlagStore.
+func (s FileFlagStore) Load(key string) ([]byte, error) {
+	b, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Save implements FlagStore.
+func (s FileFlagStore) Save(key string, flags []byte) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path(key), flags, 0644)
+}
+
+// SetFlagStore sets the store used to persist the user flags and loads the
+// flags saved for the current ROM from it.
+func (e *Emulator) SetFlagStore(s FlagStore) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flagStore = s
+	return e.loadFlags()
+}
+
+// loadFlags reads the flags for the current ROM from the flag store.
+func (e *Emulator) loadFlags() error {
+	e.flags = [FlagRegisters]byte{}
+	if e.flagStore == nil {
+		return nil
+	}
+	b, err := e.flagStore.Load(e.rom.Hash())
+	if err != nil {
+		return fmt.Errorf("load flags: %w", err)
+	}
+	copy(e.flags[:], b)
+	return nil
+}
+
+// flagCount returns the number of user flags available to the program.
+func (e *Emulator) flagCount() uint16 {
+	if e.quirks.Variant >= VariantXOCHIP {
+		return 16
+	}
+	return 8
+}
+
+// saveFlags copies V0 through Vx into the user flags and persists them.
+func (e *Emulator) saveFlags(x uint16) error {
+	if x >= e.flagCount() {
+		x = e.flagCount() - 1
+	}
+	copy(e.flags[:x+1], e.v[:x+1])
+	if e.flagStore == nil {
+		return nil
+	}
+	if err := e.flagStore.Save(e.rom.Hash(), e.flags[:e.flagCount()]); err != nil {
+		return fmt.Errorf("save flags: %w", err)
+	}
+	return nil
+}
+
+// restoreFlags copies the user flags into V0 through Vx.
+func (e *Emulator) restoreFlags(x uint16) {
+	if x >= e.flagCount() {
+		x = e.flagCount() - 1
+	}
+	copy(e.v[:x+1], e.flags[:x+1])
+}
@@ -0,0 +1,109 @@
+package emulator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSaveRestoreFlags(t *testing.T) {
+	store := NewMemoryFlagStore()
+	rom := []byte{
+		0xF3, 0x75, // LD R,V3
+		0x00, 0xFD, // EXIT
+	}
+
+	e := NewEmulator(QuirksSCHIP)
+	if err := e.SetFlagStore(store); err != nil {
+		t.Fatalf("SetFlagStore() = %v, expected nil", err)
+	}
+	if err := e.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatalf("LoadROM() = %v, expected nil", err)
+	}
+	copy(e.v[:], []byte{1, 2, 3, 4, 5})
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("Step() = %v, expected nil", err)
+	}
+
+	saved, _ := store.Load(e.ROM().Hash())
+	if exp := []byte{1, 2, 3, 4, 0, 0, 0, 0}; !bytes.Equal(saved, exp) {
+		t.Errorf("saved flags = % x, expected % x", saved, exp)
+	}
+
+	// A new emulator running the same ROM sees the saved flags.
+	e = NewEmulator(QuirksSCHIP)
+	e.SetFlagStore(store)
+	e.LoadROM(bytes.NewReader(rom))
+	e.WriteOpcode(0xF785, ProgramAddress) // LD V7,R
+	e.Step()
+	if exp := [Registers]byte{1, 2, 3, 4}; e.v != exp {
+		t.Errorf("registers = % x, expected % x", e.v, exp)
+	}
+}
+
+// Test that flags are kept separately for each ROM.
+func TestFlagsPerROM(t *testing.T) {
+	store := NewMemoryFlagStore()
+	e := NewEmulator(QuirksSCHIP)
+	e.SetFlagStore(store)
+
+	e.LoadROM(bytes.NewReader([]byte{0xF0, 0x75}))
+	e.v[0] = 0x42
+	e.Step()
+
+	e.LoadROM(bytes.NewReader([]byte{0xF0, 0x85}))
+	e.v[0] = 0x17
+	e.Step()
+	if e.v[0] != 0 {
+		t.Errorf("V0 = %#02x, expected %#02x", e.v[0], 0)
+	}
+}
+
+// Test that only 8 flags are available on SUPER-CHIP, and 16 on XO-CHIP.
+func TestFlagCount(t *testing.T) {
+	for _, tt := range []struct {
+		quirks Quirks
+		n      int
+	}{
+		{QuirksSCHIP, 8},
+		{QuirksXOCHIP, 16},
+	} {
+		e := NewEmulator(tt.quirks)
+		for r := range e.v {
+			e.v[r] = 0xFF
+		}
+		e.WriteOpcode(0xFF75, ProgramAddress)
+		e.Step()
+		n := 0
+		for _, f := range e.flags {
+			if f == 0xFF {
+				n++
+			}
+		}
+		if n != tt.n {
+			t.Errorf("%v: flags saved = %d, expected %d", tt.quirks.Variant, n, tt.n)
+		}
+	}
+}
+
+func TestFileFlagStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := FileFlagStore{Dir: dir + "/flags"}
+
+	b, err := s.Load("abc")
+	if b != nil || err != nil {
+		t.Errorf("Load() = %v, %v, expected nil, nil", b, err)
+	}
+	if err := s.Save("abc", []byte{1, 2, 3}); err != nil {
+		t.Fatalf("Save() = %v, expected nil", err)
+	}
+	b, err = s.Load("abc")
+	if !bytes.Equal(b, []byte{1, 2, 3}) || err != nil {
+		t.Errorf("Load() = % x, %v, expected 01 02 03, nil", b, err)
+	}
+}
@@ -0,0 +1,207 @@
+package emulator
+
+const (
+	// FontAddress holds the address of the small (5-byte) hex font in memory.
+	FontAddress = 0x050
+
+	// FontGlyphSize holds the number of bytes in each small font glyph.
+	FontGlyphSize = 5
+
+	// LargeFontAddress holds the address of the large (10-byte) font in memory.
+	LargeFontAddress = FontAddress + 16*FontGlyphSize
+
+	// LargeFontGlyphSize holds the number of bytes in each large font glyph.
+	LargeFontGlyphSize = 10
+)
+
+// FontSet holds the glyphs installed into the interpreter area of memory.
+// Small holds the 4x5 glyphs for the hex digits 0-F. Large holds the 8x10
+// glyphs used by SUPER-CHIP and may be empty.
+type FontSet struct {
+	Name  string
+	Small [16 * FontGlyphSize]byte
+	Large []byte
+}
+
+// FontStandard is the hex font used by most modern interpreters.
+var FontStandard = FontSet{
+	Name: "standard",
+	Small: [16 * FontGlyphSize]byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+		0x20, 0x60, 0x20, 0x20, 0x70, // 1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+		0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+		0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+		0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+		0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+	},
+}
+
+// FontVIP is the hex font from the COSMAC VIP interpreter ROM.
+var FontVIP = FontSet{
+	Name: "vip",
+	Small: [16 * FontGlyphSize]byte{
+		0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+		0x60, 0x20, 0x20, 0x20, 0x70, // 1
+		0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+		0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+		0xA0, 0xA0, 0xF0, 0x20, 0x20, // 4
+		0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+		0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+		0xF0, 0x10, 0x10, 0x10, 0x10, // 7
+		0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+		0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+		0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+		0xF0, 0x50, 0x70, 0x50, 0xF0, // B
+		0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+		0xF0, 0x50, 0x50, 0x50, 0xF0, // D
+		0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+		0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+	},
+}
+
+// FontDream6800 is the hex font from the DREAM 6800 CHIPOS monitor.
+var FontDream6800 = FontSet{
+	Name: "dream6800",
+	Small: [16 * FontGlyphSize]byte{
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x40, 0x40, 0x40, 0x40, 0x40, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0x80, 0xA0, 0xA0, 0xE0, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0xC0, 0xA0, 0xE0, 0xA0, 0xC0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0xC0, 0xA0, 0xA0, 0xA0, 0xC0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	},
+}
+
+// FontETI660 is the hex font from the ETI-660 interpreter.
+var FontETI660 = FontSet{
+	Name: "eti660",
+	Small: [16 * FontGlyphSize]byte{
+		0xE0, 0xA0, 0xA0, 0xA0, 0xE0, // 0
+		0x20, 0x20, 0x20, 0x20, 0x20, // 1
+		0xE0, 0x20, 0xE0, 0x80, 0xE0, // 2
+		0xE0, 0x20, 0xE0, 0x20, 0xE0, // 3
+		0xA0, 0xA0, 0xE0, 0x20, 0x20, // 4
+		0xE0, 0x80, 0xE0, 0x20, 0xE0, // 5
+		0xE0, 0x80, 0xE0, 0xA0, 0xE0, // 6
+		0xE0, 0x20, 0x20, 0x20, 0x20, // 7
+		0xE0, 0xA0, 0xE0, 0xA0, 0xE0, // 8
+		0xE0, 0xA0, 0xE0, 0x20, 0xE0, // 9
+		0xE0, 0xA0, 0xE0, 0xA0, 0xA0, // A
+		0x80, 0x80, 0xE0, 0xA0, 0xE0, // B
+		0xE0, 0x80, 0x80, 0x80, 0xE0, // C
+		0x20, 0x20, 0xE0, 0xA0, 0xE0, // D
+		0xE0, 0x80, 0xE0, 0x80, 0xE0, // E
+		0xE0, 0x80, 0xC0, 0x80, 0x80, // F
+	},
+}
+
+// FontSCHIP is the SUPER-CHIP 1.1 font: the standard small font plus large
+// glyphs for the decimal digits 0-9.
+var FontSCHIP = FontSet{
+	Name:  "schip",
+	Small: FontStandard.Small,
+	Large: []byte{
+		0x3C, 0x7E, 0xE7, 0xC3, 0xC3, 0xC3, 0xC3, 0xE7, 0x7E, 0x3C, // 0
+		0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3C, // 1
+		0x3E, 0x7F, 0xC3, 0x06, 0x0C, 0x18, 0x30, 0x60, 0xFF, 0xFF, // 2
+		0x3C, 0x7E, 0xC3, 0x03, 0x0E, 0x0E, 0x03, 0xC3, 0x7E, 0x3C, // 3
+		0x06, 0x0E, 0x1E, 0x36, 0x66, 0xC6, 0xFF, 0xFF, 0x06, 0x06, // 4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFC, 0xFE, 0x03, 0xC3, 0x7E, 0x3C, // 5
+		0x3E, 0x7C, 0xE0, 0xC0, 0xFC, 0xFE, 0xC3, 0xC3, 0x7E, 0x3C, // 6
+		0xFF, 0xFF, 0x03, 0x06, 0x0C, 0x18, 0x30, 0x60, 0x60, 0x60, // 7
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7E, 0x7E, 0xC3, 0xC3, 0x7E, 0x3C, // 8
+		0x3C, 0x7E, 0xC3, 0xC3, 0x7F, 0x3F, 0x03, 0x03, 0x3E, 0x7C, // 9
+	},
+}
+
+// FontXOCHIP is the font of the Octo XO-CHIP interpreter: the standard small
+// font plus large glyphs for all 16 hex digits.
+var FontXOCHIP = FontSet{
+	Name:  "xochip",
+	Small: FontStandard.Small,
+	Large: []byte{
+		0xFF, 0xFF, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, // 0
+		0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xFF, 0xFF, // 1
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // 2
+		0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 3
+		0xC3, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0x03, 0x03, // 4
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 5
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 6
+		0xFF, 0xFF, 0x03, 0x03, 0x06, 0x0C, 0x18, 0x18, 0x18, 0x18, // 7
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, // 8
+		0xFF, 0xFF, 0xC3, 0xC3, 0xFF, 0xFF, 0x03, 0x03, 0xFF, 0xFF, // 9
+		0x7E, 0xFF, 0xC3, 0xC3, 0xC3, 0xFF, 0xFF, 0xC3, 0xC3, 0xC3, // A
+		0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, 0xC3, 0xC3, 0xFC, 0xFC, // B
+		0x3C, 0xFF, 0xC3, 0xC0, 0xC0, 0xC0, 0xC0, 0xC3, 0xFF, 0x3C, // C
+		0xFC, 0xFE, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xC3, 0xFE, 0xFC, // D
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, // E
+		0xFF, 0xFF, 0xC0, 0xC0, 0xFF, 0xFF, 0xC0, 0xC0, 0xC0, 0xC0, // F
+	},
+}
+
+// FontSets holds the built-in font sets, indexed by name.
+var FontSets = map[string]FontSet{
+	FontStandard.Name:  FontStandard,
+	FontVIP.Name:       FontVIP,
+	FontDream6800.Name: FontDream6800,
+	FontETI660.Name:    FontETI660,
+	FontSCHIP.Name:     FontSCHIP,
+	FontXOCHIP.Name:    FontXOCHIP,
+}
+
+// SetFont installs the given font set into the interpreter area of memory,
+// replacing any font that was previously installed.
+func (e *Emulator) SetFont(f FontSet) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	start, end := e.fontRange()
+	for a := start; a < end; a++ {
+		e.mem[a] = 0
+	}
+	copy(e.mem[FontAddress:], f.Small[:])
+	if len(f.Large) > 16*LargeFontGlyphSize {
+		f.Large = f.Large[:16*LargeFontGlyphSize]
+	}
+	copy(e.mem[LargeFontAddress:], f.Large)
+	e.flushCache()
+	e.font = f
+}
+
+// Font returns the font set currently installed in memory.
+func (e *Emulator) Font() FontSet {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.font
+}
+
+// FontRange returns the half-open range of addresses [start, end) holding the
+// installed font set.
+func (e *Emulator) FontRange() (start, end uint16) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.fontRange()
+}
+
+func (e *Emulator) fontRange() (start, end uint16) {
+	return FontAddress, LargeFontAddress + uint16(len(e.font.Large))
+}
@@ -0,0 +1,91 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Test that NewEmulator installs the standard font.
+func TestNewEmulatorInstallsFont(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	b := e.Read(FontAddress, uint(len(FontStandard.Small)))
+	if !bytes.Equal(b, FontStandard.Small[:]) {
+		t.Errorf("mem[%#04x] = % x, expected % x", FontAddress, b, FontStandard.Small)
+	}
+	start, end := e.FontRange()
+	if start != FontAddress || end != LargeFontAddress {
+		t.Errorf("FontRange() = %#04x, %#04x, expected %#04x, %#04x", start, end, FontAddress, LargeFontAddress)
+	}
+}
+
+func TestSetFont(t *testing.T) {
+	for name, f := range FontSets {
+		t.Run(name, func(t *testing.T) {
+			e := NewEmulator(QuirksCHIP48)
+			e.SetFont(f)
+
+			start, end := e.FontRange()
+			if start >= end || end > 0x200 {
+				t.Errorf("FontRange() = %#04x, %#04x, outside the interpreter area", start, end)
+			}
+			b := e.Read(FontAddress, uint(len(f.Small)))
+			if !bytes.Equal(b, f.Small[:]) {
+				t.Errorf("small font = % x, expected % x", b, f.Small)
+			}
+			b = e.Read(LargeFontAddress, uint(len(f.Large)))
+			if !bytes.Equal(b, f.Large) {
+				t.Errorf("large font = % x, expected % x", b, f.Large)
+			}
+		})
+	}
+}
+
+// Test that XO-CHIP has large glyphs for all 16 hex digits, so LD HF,Vx
+// points at a glyph for the letters too.
+func TestXOCHIPLargeFont(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	e.v[6] = 0x0F
+	e.WriteOpcode(0xF630, ProgramAddress) // LD HF,V6
+	e.Step()
+
+	glyph := e.Read(e.i, LargeFontGlyphSize)
+	exp := FontXOCHIP.Large[0xF*LargeFontGlyphSize : 0x10*LargeFontGlyphSize]
+	if !bytes.Equal(glyph, exp) {
+		t.Errorf("mem[I] = % x, expected % x", glyph, exp)
+	}
+}
+
+// Test that switching to a font without large glyphs removes the old ones.
+func TestSetFontClearsLargeFont(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.SetFont(FontSCHIP)
+	e.SetFont(FontVIP)
+
+	for _, b := range e.Read(LargeFontAddress, uint(len(FontSCHIP.Large))) {
+		if b != 0 {
+			t.Fatalf("large font area not cleared: %#02x", b)
+		}
+	}
+}
+
+// Test that LD F,Vx points I at a glyph that draws the expected digit.
+func TestLdFVxDrawsGlyph(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	e.v[6] = 0x07
+	e.WriteOpcode(0xF629, ProgramAddress)
+	e.WriteOpcode(0xD005, ProgramAddress+2)
+
+	e.runCode()
+	e.runCode()
+
+	for row := 0; row < FontGlyphSize; row++ {
+		for col := 0; col < 8; col++ {
+			exp := (FontStandard.Small[7*FontGlyphSize+row] >> uint(7-col)) & 1
+			if e.display[row*DisplayWidth+col] != exp {
+				t.Errorf("pixel(%d,%d) = %d, expected %d", col, row, e.display[row*DisplayWidth+col], exp)
+			}
+		}
+	}
+}
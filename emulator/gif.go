@@ -0,0 +1,100 @@
+package emulator
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// gifMinDelay holds the shortest delay, in hundredths of a second, that GIF
+// viewers honour; most treat shorter delays as a tenth of a second.
+const gifMinDelay = 2
+
+// GIFRecorder is a FrameSink that records the frames it is given as an
+// animated GIF playing at TimerFrequencyHz frames per second. Consecutive
+// identical frames are stored once with a longer delay. Since GIF delays are
+// whole hundredths of a second, a frame shown for less than gifMinDelay
+// hundredths is replaced by the frame that follows it, which keeps the
+// recording in time with the emulator.
+//
+// Every image has the size of the first frame, so a recording that switches
+// to the SUPER-CHIP high resolution mode keeps its size.
+type GIFRecorder struct {
+	scale   int
+	palette color.Palette
+	width   int
+	height  int
+	g       gif.GIF
+	starts  []int // frame number at which each image is first shown
+	last    Frame
+	frames  int
+}
+
+// NewGIFRecorder returns a recorder that scales and colours frames as
+// Frame.Image does.
+func NewGIFRecorder(scale int, p color.Palette) *GIFRecorder {
+	if scale < 1 {
+		scale = 1
+	}
+	if len(p) == 0 {
+		p = DefaultPalette
+	}
+	return &GIFRecorder{scale: scale, palette: p}
+}
+
+// Frames returns the number of frames recorded.
+func (r *GIFRecorder) Frames() int {
+	return r.frames
+}
+
+// WriteFrame adds f to the recording.
+func (r *GIFRecorder) WriteFrame(f Frame) error {
+	n := r.frames
+	r.frames++
+	if len(r.starts) > 0 && f.Width == r.last.Width && bytes.Equal(f.Pixels, r.last.Pixels) {
+		return nil
+	}
+	r.last = Frame{Width: f.Width, Height: f.Height, Pixels: append([]byte(nil), f.Pixels...)}
+	if r.width == 0 {
+		r.width, r.height = f.Width*r.scale, f.Height*r.scale
+	}
+	img := f.resize(r.width, r.height, r.palette)
+	if k := len(r.starts) - 1; k >= 0 && centiseconds(n)-centiseconds(r.starts[k]) < gifMinDelay {
+		if k > 0 && bytes.Equal(img.Pix, r.g.Image[k-1].Pix) {
+			// The short image was all that separated two identical ones.
+			r.g.Image = r.g.Image[:k]
+			r.starts = r.starts[:k]
+			return nil
+		}
+		r.g.Image[k] = img
+		return nil
+	}
+	r.g.Image = append(r.g.Image, img)
+	r.starts = append(r.starts, n)
+	return nil
+}
+
+// Encode writes the recording to w. The recording may be continued and
+// encoded again afterwards.
+func (r *GIFRecorder) Encode(w io.Writer) error {
+	r.g.Delay = make([]int, len(r.starts))
+	for k, start := range r.starts {
+		end := r.frames
+		if k+1 < len(r.starts) {
+			end = r.starts[k+1]
+		}
+		r.g.Delay[k] = centiseconds(end) - centiseconds(start)
+		if r.g.Delay[k] < gifMinDelay {
+			// Only the last image can be this short.
+			r.g.Delay[k] = gifMinDelay
+		}
+	}
+	return gif.EncodeAll(w, &r.g)
+}
+
+// centiseconds returns the time at which frame n starts, rounded to the
+// nearest hundredth of a second.
+func centiseconds(n int) int {
+	return (n*100 + TimerFrequencyHz/2) / TimerFrequencyHz
+}
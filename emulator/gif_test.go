@@ -0,0 +1,77 @@
+package emulator
+
+import (
+	"bytes"
+	"image/gif"
+	"testing"
+)
+
+func TestGIFRecorder(t *testing.T) {
+	r := NewGIFRecorder(2, nil)
+	blank := Frame{Width: DisplayWidth, Height: DisplayHeight, Pixels: make([]byte, DisplayWidth*DisplayHeight)}
+	lit := Frame{Width: DisplayWidth, Height: DisplayHeight, Pixels: make([]byte, DisplayWidth*DisplayHeight)}
+	lit.Pixels[0] = 1
+
+	// One second of frames: blank for 31, lit for 1, which lasts only a
+	// hundredth of a second and is dropped, blank again for 4 and lit for
+	// the rest.
+	for n := 0; n < TimerFrequencyHz; n++ {
+		if n < 31 || n > 31 && n < 36 {
+			r.WriteFrame(blank)
+		} else {
+			r.WriteFrame(lit)
+		}
+	}
+	if r.Frames() != TimerFrequencyHz {
+		t.Errorf("Frames() = %d, expected %d", r.Frames(), TimerFrequencyHz)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Encode(&buf); err != nil {
+		t.Fatalf("Encode() = %v, expected nil", err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() = %v, expected nil", err)
+	}
+	exp := []int{60, 40}
+	if len(g.Delay) != len(exp) {
+		t.Fatalf("Delay = %v, expected %v", g.Delay, exp)
+	}
+	total := 0
+	for k, d := range g.Delay {
+		total += d
+		if d != exp[k] {
+			t.Errorf("Delay[%d] = %d, expected %d", k, d, exp[k])
+		}
+	}
+	if total != 100 {
+		t.Errorf("total delay = %d, expected 100", total)
+	}
+	if g.Image[0].Pix[0] != 0 || g.Image[1].Pix[0] != 1 {
+		t.Errorf("images do not match the frames")
+	}
+}
+
+// Test that frames keep the size of the first frame after a resolution change.
+func TestGIFRecorderResize(t *testing.T) {
+	r := NewGIFRecorder(1, nil)
+	r.WriteFrame(Frame{Width: DisplayWidth, Height: DisplayHeight, Pixels: make([]byte, DisplayWidth*DisplayHeight)})
+	hires := Frame{Width: HiresDisplayWidth, Height: HiresDisplayHeight, Pixels: make([]byte, HiresDisplayWidth*HiresDisplayHeight)}
+	hires.Pixels[0] = 1
+	for n := 0; n < 10; n++ {
+		r.WriteFrame(hires)
+	}
+
+	var buf bytes.Buffer
+	r.Encode(&buf)
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() = %v, expected nil", err)
+	}
+	for k, img := range g.Image {
+		if b := img.Bounds(); b.Dx() != DisplayWidth || b.Dy() != DisplayHeight {
+			t.Errorf("image %d bounds = %v, expected %dx%d", k, b, DisplayWidth, DisplayHeight)
+		}
+	}
+}
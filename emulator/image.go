@@ -0,0 +1,87 @@
+package emulator
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// DefaultPalette holds the colours used for each pixel value when no palette
+// is given: black for unlit pixels and white for lit pixels, with orange and
+// brown for the second XO-CHIP bitplane and the overlap of both planes.
+var DefaultPalette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	color.RGBA{0xFF, 0x88, 0x00, 0xFF},
+	color.RGBA{0x88, 0x55, 0x00, 0xFF},
+}
+
+// FrameSink receives a copy of the display at the end of every frame run by
+// RunFrame or Run, including the frame in which the program halts, so
+// recordings play back at TimerFrequencyHz frames per second.
+type FrameSink interface {
+	WriteFrame(f Frame) error
+}
+
+// SetFrameSink sets the sink that receives the display at the end of every
+// frame. Passing nil disables it.
+func (e *Emulator) SetFrameSink(sink FrameSink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.video = sink
+}
+
+// writeFrame writes the display to the frame sink.
+func (e *Emulator) writeFrame() error {
+	if e.video == nil {
+		return nil
+	}
+	if err := e.video.WriteFrame(e.frame()); err != nil {
+		return fmt.Errorf("video: %w", err)
+	}
+	return nil
+}
+
+// Image returns the frame as an image with each pixel scaled to scale x scale
+// pixels. Pixel values index p, which defaults to DefaultPalette; values past
+// the end of p use its last colour.
+func (f Frame) Image(scale int, p color.Palette) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	return f.resize(f.Width*scale, f.Height*scale, p)
+}
+
+// resize returns the frame scaled to width x height pixels using the nearest
+// pixel.
+func (f Frame) resize(width, height int, p color.Palette) *image.Paletted {
+	if len(p) == 0 {
+		p = DefaultPalette
+	}
+	img := image.NewPaletted(image.Rect(0, 0, width, height), p)
+	for y := 0; y < height; y++ {
+		sy := y * f.Height / height
+		for x := 0; x < width; x++ {
+			c := f.At(x*f.Width/width, sy)
+			if int(c) >= len(p) {
+				c = byte(len(p) - 1)
+			}
+			img.Pix[y*img.Stride+x] = c
+		}
+	}
+	return img
+}
+
+// WritePNG writes the frame to w as a PNG image, scaled and coloured as by
+// Image.
+func (f Frame) WritePNG(w io.Writer, scale int, p color.Palette) error {
+	return png.Encode(w, f.Image(scale, p))
+}
+
+// Screenshot returns the current display as an image, scaled and coloured as
+// by Frame.Image.
+func (e *Emulator) Screenshot(scale int, p color.Palette) *image.Paletted {
+	return e.Frame().Image(scale, p)
+}
@@ -0,0 +1,88 @@
+package emulator
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// frameRecorder is a FrameSink that records the frames written to it.
+type frameRecorder struct {
+	frames []Frame
+	err    error
+}
+
+func (r *frameRecorder) WriteFrame(f Frame) error {
+	r.frames = append(r.frames, f)
+	return r.err
+}
+
+func TestFrameImage(t *testing.T) {
+	f := Frame{Width: 2, Height: 1, Pixels: []byte{1, 3}}
+	p := color.Palette{color.Black, color.White}
+
+	img := f.Image(2, p)
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Fatalf("Bounds() = %v, expected 4x2", b)
+	}
+	exp := []byte{1, 1, 1, 1, 1, 1, 1, 1}
+	if !bytes.Equal(img.Pix, exp) {
+		t.Errorf("Pix = %v, expected %v", img.Pix, exp)
+	}
+
+	img = f.Image(1, nil)
+	if img.At(1, 0) != DefaultPalette[3] {
+		t.Errorf("At(1, 0) = %v, expected %v", img.At(1, 0), DefaultPalette[3])
+	}
+}
+
+func TestScreenshotPNG(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.display[DisplayWidth+2] = 1
+
+	var buf bytes.Buffer
+	if err := e.Frame().WritePNG(&buf, 3, nil); err != nil {
+		t.Fatalf("WritePNG() = %v, expected nil", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode() = %v, expected nil", err)
+	}
+	if b := img.Bounds(); b.Dx() != DisplayWidth*3 || b.Dy() != DisplayHeight*3 {
+		t.Errorf("Bounds() = %v, expected %dx%d", b, DisplayWidth*3, DisplayHeight*3)
+	}
+	r, _, _, _ := img.At(8, 5).RGBA()
+	if r != 0xFFFF {
+		t.Errorf("pixel (2, 1) is not lit")
+	}
+	if c := e.Screenshot(3, nil).At(5, 5); c != DefaultPalette[0] {
+		t.Errorf("Screenshot().At(5, 5) = %v, expected %v", c, DefaultPalette[0])
+	}
+}
+
+func TestFrameSink(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	rec := &frameRecorder{}
+	e.SetFrameSink(rec)
+
+	e.WriteOpcode(0x00E0, 0x200) // CLS
+	e.WriteOpcode(0x1202, 0x202) // JP 0x202
+	if err := e.RunFrame(1); err != nil {
+		t.Fatalf("RunFrame() = %v, expected nil", err)
+	}
+	if err := e.RunFrame(1); err != ErrHalted {
+		t.Fatalf("RunFrame() = %v, expected ErrHalted", err)
+	}
+	e.RunFrame(1)
+	if len(rec.frames) != 2 {
+		t.Errorf("frames = %d, expected 2", len(rec.frames))
+	}
+
+	rec.err = errors.New("full")
+	e.Reset()
+	if err := e.RunFrame(1); !errors.Is(err, rec.err) {
+		t.Errorf("RunFrame() = %v, expected %v", err, rec.err)
+	}
+}
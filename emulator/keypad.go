@@ -0,0 +1,92 @@
+package emulator
+
+// Keyboard is a source of keypad input, such as a terminal, a network
+// connection or a script. Poll is called at the start of every frame and
+// returns the state of the 16 keys, true meaning the key is held down.
+type Keyboard interface {
+	Poll() [Keys]bool
+}
+
+// SetKeyboard attaches kb as the keypad input source, replacing the state set
+// by PressKey and ReleaseKey at the start of every frame. Passing nil detaches
+// the keyboard.
+func (e *Emulator) SetKeyboard(kb Keyboard) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kb = kb
+}
+
+// PressKey marks key k (0x0-0xF) as held down.
+func (e *Emulator) PressKey(k byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keys[k&0x0F] = true
+}
+
+// ReleaseKey marks key k (0x0-0xF) as released.
+func (e *Emulator) ReleaseKey(k byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keys[k&0x0F] = false
+}
+
+// KeyPressed reports whether key k (0x0-0xF) is held down.
+func (e *Emulator) KeyPressed(k byte) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.keys[k&0x0F]
+}
+
+// pollKeyboard refreshes the keypad from the attached keyboard, if any.
+func (e *Emulator) pollKeyboard() {
+	if e.kb != nil {
+		e.keys = e.kb.Poll()
+	}
+}
+
+// waitKey implements the blocking part of LD Vx,K. Like the COSMAC VIP it
+// waits for a key to be pressed and then released, and only reports the key
+// once it has been let go.
+func (e *Emulator) waitKey() (byte, bool) {
+	if !e.held {
+		for k, down := range e.keys {
+			if down {
+				e.held, e.heldKey = true, byte(k)
+				break
+			}
+		}
+		return 0, false
+	}
+	if e.keys[e.heldKey] {
+		return 0, false
+	}
+	e.held = false
+	return e.heldKey, true
+}
+
+// KeyEvent is a key press or release at a given frame.
+type KeyEvent struct {
+	Frame int
+	Key   byte
+	Down  bool
+}
+
+// ScriptedKeyboard is a Keyboard that replays a fixed sequence of key events,
+// which must be ordered by frame. It is useful for tests and replays.
+type ScriptedKeyboard struct {
+	Events []KeyEvent
+	frame  int
+	next   int
+	keys   [Keys]bool
+}
+
+// Poll applies the events for the current frame and advances to the next.
+func (s *ScriptedKeyboard) Poll() [Keys]bool {
+	for s.next < len(s.Events) && s.Events[s.next].Frame <= s.frame {
+		ev := s.Events[s.next]
+		s.keys[ev.Key&0x0F] = ev.Down
+		s.next++
+	}
+	s.frame++
+	return s.keys
+}
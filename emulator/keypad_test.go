@@ -0,0 +1,75 @@
+package emulator
+
+import (
+	"testing"
+)
+
+func TestPressReleaseKey(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	e.PressKey(0x0A)
+	if !e.KeyPressed(0x0A) {
+		t.Errorf("KeyPressed(0xA) = false, expected true")
+	}
+	e.ReleaseKey(0x0A)
+	if e.KeyPressed(0x0A) {
+		t.Errorf("KeyPressed(0xA) = true, expected false")
+	}
+}
+
+// Test that LD Vx,K blocks until a key has been pressed and released.
+func TestLdVxK(t *testing.T) {
+	e := &Emulator{}
+
+	e.WriteOpcode(0xF60A, 0x000)
+
+	// no key pressed, execution blocks on the same instruction
+	e.runCode()
+	if e.pc != 0x0000 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x0000)
+	}
+
+	// key held down, execution still blocks
+	e.PressKey(0x0B)
+	e.runCode()
+	e.runCode()
+	if e.pc != 0x0000 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x0000)
+	}
+
+	// pressing a second key does not change the key reported
+	e.PressKey(0x03)
+	e.ReleaseKey(0x0B)
+	e.runCode()
+	if e.pc != 0x0002 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x0002)
+	}
+	if e.v[6] != 0x0B {
+		t.Errorf("V6 = %#02x, expected %#02x", e.v[6], 0x0B)
+	}
+}
+
+func TestScriptedKeyboard(t *testing.T) {
+	e := &Emulator{}
+	e.SetKeyboard(&ScriptedKeyboard{Events: []KeyEvent{
+		{Frame: 1, Key: 0x05, Down: true},
+		{Frame: 3, Key: 0x05, Down: false},
+	}})
+
+	e.WriteOpcode(0xF00A, 0x000) // LD V0,K
+	e.WriteOpcode(0x1002, 0x002) // JP 0x002
+
+	frames := 0
+	for e.pc != 0x0002 && frames < 10 {
+		if err := e.RunFrame(1); err != nil {
+			t.Fatalf("RunFrame() = %v, expected nil", err)
+		}
+		frames++
+	}
+	if frames != 4 {
+		t.Errorf("frames = %d, expected %d", frames, 4)
+	}
+	if e.v[0] != 0x05 {
+		t.Errorf("V0 = %#02x, expected %#02x", e.v[0], 0x05)
+	}
+}
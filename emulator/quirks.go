@@ -0,0 +1,108 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+// Variant identifies a CHIP-8 dialect. Each variant includes the
+// instructions of the ones before it.
+type Variant int
+
+const (
+	// VariantCHIP8 is the original CHIP-8 instruction set.
+	VariantCHIP8 Variant = iota
+
+	// VariantSCHIP adds the SUPER-CHIP 1.1 instructions.
+	VariantSCHIP
+
+	// VariantXOCHIP adds the XO-CHIP instructions.
+	VariantXOCHIP
+)
+
+var variantNames = map[Variant]string{
+	VariantCHIP8:  "chip8",
+	VariantSCHIP:  "schip",
+	VariantXOCHIP: "xochip",
+}
+
+func (v Variant) String() string {
+	if n, ok := variantNames[v]; ok {
+		return n
+	}
+	return fmt.Sprintf("variant(%d)", int(v))
+}
+
+// Quirks selects between the behaviours that CHIP-8 interpreters disagree on.
+// The zero value behaves like a modern interpreter: shifts operate on Vx in
+// place, I is left unchanged by LD [I],Vx and LD Vx,[I], Bnnn jumps to
+// nnn+V0, logic operations leave VF alone, sprites are clipped at the edges
+// of the display and drawing does not wait for the display.
+type Quirks struct {
+	// Variant selects the instruction set extensions that are enabled.
+	Variant Variant
+
+	// ShiftVy makes SHR and SHL shift Vy and store the result in Vx.
+	ShiftVy bool
+
+	// IncrementI makes LD [I],Vx and LD Vx,[I] leave I pointing past the
+	// last register transferred.
+	IncrementI bool
+
+	// JumpVx makes Bxnn jump to xnn+Vx instead of nnn+V0.
+	JumpVx bool
+
+	// ResetVF makes OR, AND and XOR set VF to 0.
+	ResetVF bool
+
+	// WrapSprites makes sprite pixels that run off the edge of the display
+	// reappear on the opposite edge instead of being clipped.
+	WrapSprites bool
+
+	// DisplayWait makes DRW wait for the display to refresh, so that at most
+	// one sprite is drawn per frame.
+	DisplayWait bool
+}
+
+var (
+	// QuirksVIP matches the original COSMAC VIP interpreter.
+	QuirksVIP = Quirks{
+		ShiftVy:     true,
+		IncrementI:  true,
+		ResetVF:     true,
+		DisplayWait: true,
+	}
+
+	// QuirksCHIP48 matches CHIP-48 on the HP-48 calculators.
+	QuirksCHIP48 = Quirks{
+		JumpVx: true,
+	}
+
+	// QuirksSCHIP matches SUPER-CHIP 1.1.
+	QuirksSCHIP = Quirks{
+		Variant: VariantSCHIP,
+		JumpVx:  true,
+	}
+
+	// QuirksXOCHIP matches XO-CHIP as implemented by Octo.
+	QuirksXOCHIP = Quirks{
+		Variant:     VariantXOCHIP,
+		ShiftVy:     true,
+		IncrementI:  true,
+		WrapSprites: true,
+	}
+)
+
+// QuirksPresets holds the named quirks presets.
+var QuirksPresets = map[string]Quirks{
+	"vip":    QuirksVIP,
+	"chip48": QuirksCHIP48,
+	"schip":  QuirksSCHIP,
+	"xochip": QuirksXOCHIP,
+}
+
+// Quirks returns the quirks the emulator was created with.
+func (e *Emulator) Quirks() Quirks {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.quirks
+}
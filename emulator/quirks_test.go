@@ -0,0 +1,127 @@
+package emulator
+
+import (
+	"testing"
+)
+
+func TestQuirkShiftVy(t *testing.T) {
+	tests := []struct {
+		name   string
+		quirks Quirks
+		opcode uint16
+		exp    byte
+		flag   byte
+	}{
+		{"SHR Vx", Quirks{}, 0x8126, 0x40, 0},
+		{"SHR Vy", Quirks{ShiftVy: true}, 0x8126, 0x01, 1},
+		{"SHL Vx", Quirks{}, 0x812E, 0x00, 1},
+		{"SHL Vy", Quirks{ShiftVy: true}, 0x812E, 0x06, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Emulator{quirks: tt.quirks}
+			e.WriteOpcode(tt.opcode, 0x000)
+			e.v[1] = 0x80
+			e.v[2] = 0x03
+
+			e.runCode()
+
+			if e.v[1] != tt.exp {
+				t.Errorf("V1 = %#02x, expected %#02x", e.v[1], tt.exp)
+			}
+			if e.v[0xF] != tt.flag {
+				t.Errorf("VF = %#02x, expected %#02x", e.v[0xF], tt.flag)
+			}
+		})
+	}
+}
+
+func TestQuirkIncrementI(t *testing.T) {
+	for _, opcode := range []uint16{0xF355, 0xF365} {
+		for _, inc := range []bool{false, true} {
+			e := &Emulator{quirks: Quirks{IncrementI: inc}}
+			e.WriteOpcode(opcode, 0x000)
+			e.i = 0x300
+
+			e.runCode()
+
+			exp := uint16(0x300)
+			if inc {
+				exp = 0x304
+			}
+			if e.i != exp {
+				t.Errorf("%#04x with IncrementI=%v: I = %#04x, expected %#04x", opcode, inc, e.i, exp)
+			}
+		}
+	}
+}
+
+func TestQuirkJumpVx(t *testing.T) {
+	for _, jvx := range []bool{false, true} {
+		e := &Emulator{quirks: Quirks{JumpVx: jvx}}
+		e.WriteOpcode(0xB320, 0x000)
+		e.v[0] = 0x01
+		e.v[3] = 0x02
+
+		e.runCode()
+
+		exp := uint16(0x0321)
+		if jvx {
+			exp = 0x0322
+		}
+		if e.pc != exp {
+			t.Errorf("JumpVx=%v: PC = %#04x, expected %#04x", jvx, e.pc, exp)
+		}
+	}
+}
+
+func TestQuirkResetVF(t *testing.T) {
+	for _, opcode := range []uint16{0x8121, 0x8122, 0x8123} {
+		for _, reset := range []bool{false, true} {
+			e := &Emulator{quirks: Quirks{ResetVF: reset}}
+			e.WriteOpcode(opcode, 0x000)
+			e.v[0xF] = 0x07
+
+			e.runCode()
+
+			exp := byte(0x07)
+			if reset {
+				exp = 0
+			}
+			if e.v[0xF] != exp {
+				t.Errorf("%#04x with ResetVF=%v: VF = %#02x, expected %#02x", opcode, reset, e.v[0xF], exp)
+			}
+		}
+	}
+}
+
+// Test that with the DisplayWait quirk only one sprite is drawn per frame.
+func TestQuirkDisplayWait(t *testing.T) {
+	for _, wait := range []bool{false, true} {
+		e := &Emulator{quirks: Quirks{DisplayWait: wait}}
+		e.WriteOpcode(0xD001, 0x000)
+		e.WriteOpcode(0xD001, 0x002)
+		e.WriteOpcode(0x7101, 0x004)
+
+		if err := e.RunFrame(3); err != nil {
+			t.Fatalf("RunFrame() = %v, expected nil", err)
+		}
+
+		exp := uint16(0x0006)
+		if wait {
+			exp = 0x0002
+		}
+		if e.pc != exp {
+			t.Errorf("DisplayWait=%v: PC = %#04x, expected %#04x", wait, e.pc, exp)
+		}
+	}
+}
+
+func TestQuirksPresets(t *testing.T) {
+	for name, q := range QuirksPresets {
+		e := NewEmulator(q)
+		if e.Quirks() != q {
+			t.Errorf("%s: Quirks() = %+v, expected %+v", name, e.Quirks(), q)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package emulator
+
+// maxBlockSize holds the maximum number of instructions compiled into one
+// block.
+const maxBlockSize = 64
+
+// link executes the instruction compiled into it and those that follow it in
+// its block, at most budget in all, and returns the number executed.
+type link func(e *Emulator, budget int) int
+
+// block holds a run of straight-line instructions compiled to a chain of
+// links. A block with no instructions marks an address whose first
+// instruction cannot be compiled, so it is not compiled again.
+type block struct {
+	n   int
+	run link
+}
+
+// SetRecompiler enables or disables the recompiler, which is disabled by
+// default. With the recompiler RunFrame and Run translate each run of
+// straight-line register and timer instructions, up to a jump or skip, into a
+// chain of Go closures the first time it is reached. Everything else is left
+// to the interpreter, as is any code the program writes to after it was
+// compiled. Instructions are not traced while a block runs, so the
+// recompiler is bypassed while a tracer is set.
+func (e *Emulator) SetRecompiler(on bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.recompile = on
+	e.blocks, e.compiled, e.interpret = nil, nil, nil
+}
+
+// block returns the block at the pc if it can run, compiling it if needed, or
+// nil if the next instruction must be interpreted.
+func (e *Emulator) block() *block {
+	if !e.recompile || e.tracer != nil || e.err != nil || int(e.pc)+1 >= e.memSize() {
+		return nil
+	}
+	if len(e.blocks) != e.memSize() {
+		e.blocks = make([]*block, e.memSize())
+		e.compiled = make([]bool, e.memSize())
+		e.interpret = make([]bool, e.memSize())
+	}
+	b := e.blocks[e.pc]
+	if b == nil {
+		b = e.compile(e.pc)
+		e.blocks[e.pc] = b
+	}
+	if b.n == 0 {
+		return nil
+	}
+	return b
+}
+
+// compile compiles the block starting at pc, which ends after a jump or skip,
+// before the first instruction that cannot be compiled or after
+// maxBlockSize instructions.
+func (e *Emulator) compile(pc uint16) *block {
+	var ins []Instruction
+	var addrs []uint16
+	addr := int(pc)
+	for len(ins) < maxBlockSize {
+		if addr+1 >= e.memSize() || e.interpret[addr] || e.interpret[addr+1] {
+			break
+		}
+		in := e.decodeAt(uint16(addr))
+		if !compilable(in, uint16(addr)) {
+			break
+		}
+		if in.Size == 4 && (addr+3 >= e.memSize() || e.interpret[addr+2] || e.interpret[addr+3]) {
+			break
+		}
+		ins = append(ins, in)
+		addrs = append(addrs, uint16(addr))
+		addr += in.Size
+		if in.Op == OpJump || in.IsSkip() {
+			break
+		}
+	}
+	for a := int(pc); a < addr; a++ {
+		e.compiled[a] = true
+	}
+
+	end := uint16(addr)
+	next := link(func(e *Emulator, budget int) int {
+		e.pc = end
+		return 0
+	})
+	if n := len(ins); n > 0 && (ins[n-1].Op == OpJump || ins[n-1].IsSkip()) {
+		// The branch sets the pc itself.
+		next = func(e *Emulator, budget int) int { return 0 }
+	}
+	for k := len(ins) - 1; k >= 0; k-- {
+		next = e.chain(ins[k], addrs[k], next)
+	}
+	return &block{n: len(ins), run: next}
+}
+
+// compilable reports whether in, at addr, can be compiled. These are the
+// instructions that cannot fault and change neither the display, the memory
+// nor the stack.
+func compilable(in Instruction, addr uint16) bool {
+	switch in.Op {
+	case OpLoadByte, OpAddByte, OpMove, OpOr, OpAnd, OpXor, OpAdd, OpSub,
+		OpShr, OpSubn, OpShl, OpLoadI, OpLoadILong, OpAddI, OpFont, OpBigFont,
+		OpRand, OpGetDelay, OpSetDelay,
+		OpSkipEqByte, OpSkipNeByte, OpSkipEqReg, OpSkipNeReg:
+		return true
+	case OpJump:
+		// A jump to itself halts the program, which is left to the
+		// interpreter.
+		return in.NNN != addr
+	}
+	return false
+}
+
+// chain returns a link that executes in, at addr, and then calls next. The
+// link stops at in, leaving the pc on it, once the budget is used up.
+func (e *Emulator) chain(in Instruction, addr uint16, next link) link {
+	exec := e.translate(in, addr+uint16(in.Size))
+	return func(e *Emulator, budget int) int {
+		if budget == 0 {
+			e.pc = addr
+			return 0
+		}
+		exec(e)
+		return next(e, budget-1) + 1
+	}
+}
+
+// translate returns a function that executes in, with the quirks resolved
+// when it is compiled. Branches set the pc, relative to next, the address of
+// the instruction that follows in; nothing else does.
+func (e *Emulator) translate(in Instruction, next uint16) func(e *Emulator) {
+	x, y, kk, nnn := in.X, in.Y, in.KK, in.NNN
+	if (in.Op == OpShr || in.Op == OpShl) && !e.quirks.ShiftVy {
+		y = x
+	}
+	resetVF := e.quirks.ResetVF
+	switch in.Op {
+	case OpLoadByte:
+		return func(e *Emulator) { e.v[x] = kk }
+	case OpAddByte:
+		return func(e *Emulator) { e.v[x] += kk }
+	case OpMove:
+		return func(e *Emulator) { e.v[x] = e.v[y] }
+	case OpOr:
+		if resetVF {
+			return func(e *Emulator) {
+				e.v[x] |= e.v[y]
+				e.v[0xF] = 0
+			}
+		}
+		return func(e *Emulator) { e.v[x] |= e.v[y] }
+	case OpAnd:
+		if resetVF {
+			return func(e *Emulator) {
+				e.v[x] &= e.v[y]
+				e.v[0xF] = 0
+			}
+		}
+		return func(e *Emulator) { e.v[x] &= e.v[y] }
+	case OpXor:
+		if resetVF {
+			return func(e *Emulator) {
+				e.v[x] ^= e.v[y]
+				e.v[0xF] = 0
+			}
+		}
+		return func(e *Emulator) { e.v[x] ^= e.v[y] }
+	case OpAdd:
+		return func(e *Emulator) {
+			sum := uint16(e.v[x]) + uint16(e.v[y])
+			e.v[x] = byte(sum)
+			e.v[0xF] = byte(sum >> 8)
+		}
+	case OpSub:
+		return func(e *Emulator) {
+			flag := boolByte(e.v[x] >= e.v[y])
+			e.v[x] -= e.v[y]
+			e.v[0xF] = flag
+		}
+	case OpShr:
+		return func(e *Emulator) {
+			flag := e.v[y] & 0x01
+			e.v[x] = e.v[y] >> 1
+			e.v[0xF] = flag
+		}
+	case OpSubn:
+		return func(e *Emulator) {
+			flag := boolByte(e.v[y] >= e.v[x])
+			e.v[x] = e.v[y] - e.v[x]
+			e.v[0xF] = flag
+		}
+	case OpShl:
+		return func(e *Emulator) {
+			flag := e.v[y] >> 7
+			e.v[x] = e.v[y] << 1
+			e.v[0xF] = flag
+		}
+	case OpLoadI, OpLoadILong:
+		return func(e *Emulator) { e.i = nnn }
+	case OpAddI:
+		return func(e *Emulator) { e.i += uint16(e.v[x]) }
+	case OpFont:
+		return func(e *Emulator) { e.i = FontAddress + uint16(e.v[x]&0x0F)*FontGlyphSize }
+	case OpBigFont:
+		return func(e *Emulator) { e.i = LargeFontAddress + uint16(e.v[x]&0x0F)*LargeFontGlyphSize }
+	case OpRand:
+		return func(e *Emulator) { e.v[x] = e.random() & kk }
+	case OpGetDelay:
+		return func(e *Emulator) { e.v[x] = e.dt }
+	case OpSetDelay:
+		return func(e *Emulator) { e.dt = e.v[x] }
+	case OpJump:
+		return func(e *Emulator) { e.pc = nnn }
+	case OpSkipEqByte:
+		return func(e *Emulator) { e.branch(next, e.v[x] == kk) }
+	case OpSkipNeByte:
+		return func(e *Emulator) { e.branch(next, e.v[x] != kk) }
+	case OpSkipEqReg:
+		return func(e *Emulator) { e.branch(next, e.v[x] == e.v[y]) }
+	case OpSkipNeReg:
+		return func(e *Emulator) { e.branch(next, e.v[x] != e.v[y]) }
+	}
+	panic("emulator: cannot translate " + in.Op.String())
+}
+
+// branch moves the pc to next and skips the instruction there if skip is
+// true.
+func (e *Emulator) branch(next uint16, skip bool) {
+	e.pc = next
+	if skip {
+		e.skip()
+	}
+}
+
+// invalidateBlocks handles a write to the n bytes of memory at addr. If any
+// of them were compiled the program modifies its own code, so every block is
+// dropped and those bytes are left to the interpreter from then on.
+func (e *Emulator) invalidateBlocks(addr, n int) {
+	hit := false
+	for a := addr; a < addr+n && a < len(e.compiled); a++ {
+		if e.compiled[a] {
+			e.interpret[a] = true
+			hit = true
+		}
+	}
+	if hit {
+		e.dropBlocks()
+	}
+}
+
+// dropBlocks drops every compiled block.
+func (e *Emulator) dropBlocks() {
+	for a := range e.blocks {
+		e.blocks[a] = nil
+		e.compiled[a] = false
+	}
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,159 @@
+package emulator
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// sameMachine reports whether a and b agree on everything a program can
+// observe or change.
+func sameMachine(a, b *Emulator) bool {
+	return a.State() == b.State() && a.mem == b.mem && a.display == b.display &&
+		a.plane == b.plane && a.hires == b.hires && fmt.Sprint(a.err) == fmt.Sprint(b.err)
+}
+
+// randomProgram returns n random instructions for v, which jump, call and
+// load I only within the program so that stores may overwrite it.
+func randomProgram(r *rand.Rand, n int, v Variant) []byte {
+	templates := []uint16{
+		0x00E0, 0x00EE, 0x1000, 0x2000, 0x3000, 0x4000, 0x5000, 0x6000,
+		0x7000, 0x8000, 0x8001, 0x8002, 0x8003, 0x8004, 0x8005, 0x8006,
+		0x8007, 0x800E, 0x9000, 0xA000, 0xB000, 0xC000, 0xD000, 0xF007,
+		0xF015, 0xF018, 0xF01E, 0xF029, 0xF033, 0xF055, 0xF065,
+	}
+	if v >= VariantSCHIP {
+		templates = append(templates, 0xF030)
+	}
+	if v >= VariantXOCHIP {
+		templates = append(templates, 0xF000, 0x5002, 0x5003)
+	}
+	rom := make([]byte, 0, 2*n)
+	for len(rom) < 2*n {
+		op := templates[r.Intn(len(templates))]
+		x, y := uint16(r.Intn(16)), uint16(r.Intn(16))
+		addr := ProgramAddress + uint16(r.Intn(n))*2
+		switch op & 0xF000 {
+		case 0x1000, 0x2000, 0xA000, 0xB000:
+			op |= addr
+		case 0x3000, 0x4000, 0x6000, 0x7000, 0xC000:
+			op |= x<<8 | uint16(r.Intn(256))
+		case 0x5000, 0x8000, 0x9000:
+			op |= x<<8 | y<<4
+		case 0xD000:
+			op |= x<<8 | y<<4 | uint16(r.Intn(16))
+		case 0xF000:
+			if op != 0xF000 {
+				op |= x << 8
+			}
+		}
+		rom = append(rom, byte(op>>8), byte(op))
+		if op == 0xF000 {
+			rom = append(rom, byte(addr>>8), byte(addr))
+		}
+	}
+	return rom[:2*n]
+}
+
+// Test that random programs leave the recompiler in the same state as the
+// interpreter after every frame, including those that overwrite their own
+// code and those that fault. Half of them ignore faults to run for longer.
+func TestRecompilerDifferential(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, q := range []Quirks{QuirksVIP, QuirksCHIP48, QuirksSCHIP, QuirksXOCHIP} {
+		for p := 0; p < 100; p++ {
+			rom := randomProgram(r, 32+r.Intn(64), q.Variant)
+			seed := r.Int63()
+			a := NewEmulator(q)
+			b := NewEmulator(q)
+			b.SetRecompiler(true)
+			for _, e := range []*Emulator{a, b} {
+				e.Seed(seed)
+				if p%2 == 1 {
+					e.SetFaultPolicy(FaultIgnore, nil)
+				}
+				e.Write(ProgramAddress, rom)
+			}
+			for frame := 0; frame < 30; frame++ {
+				n := 1 + frame*7%40
+				errA, errB := a.RunFrame(n), b.RunFrame(n)
+				if !sameMachine(a, b) || fmt.Sprint(errA) != fmt.Sprint(errB) {
+					t.Fatalf("%v program %d frame %d:\nrom % x\ninterpreter %v\n%v\nrecompiler %v\n%v",
+						q.Variant, p, frame, rom, errA, a.State(), errB, b.State())
+				}
+				if errA != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Test that a loop is compiled into a block that runs part way when the frame
+// ends inside it.
+func TestRecompilerBlocks(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.SetRecompiler(true)
+	e.Write(ProgramAddress, benchmarkROM)
+	if err := e.RunFrame(3); err != nil {
+		t.Fatalf("RunFrame() = %v, expected nil", err)
+	}
+	if e.pc != 0x206 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x206)
+	}
+	b := e.blocks[ProgramAddress]
+	if b == nil || b.n != 7 {
+		t.Fatalf("block at %#04x = %+v, expected 7 instructions", ProgramAddress, b)
+	}
+	if err := e.RunFrame(4); err != nil {
+		t.Fatalf("RunFrame() = %v, expected nil", err)
+	}
+	if e.pc != 0x20E || e.v[0] != 1 || e.i != 0x301 {
+		t.Errorf("PC = %#04x, V0 = %d, I = %#04x, expected 0x20E, 1, 0x301", e.pc, e.v[0], e.i)
+	}
+}
+
+// Test that code overwritten after it was compiled is interpreted from then
+// on.
+func TestRecompilerSelfModifyingCode(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.SetRecompiler(true)
+	e.Write(ProgramAddress, []byte{
+		0x61, 0x01, // 200: LD V1,1 (replaced by LD V2,5)
+		0x73, 0x01, // 202: ADD V3,1
+		0x33, 0x01, // 204: SE V3,1
+		0x12, 0x06, // 206: JP 0x206
+		0x60, 0x62, // 208: LD V0,0x62
+		0x61, 0x05, // 20A: LD V1,5
+		0xA2, 0x00, // 20C: LD I,0x200
+		0xF1, 0x55, // 20E: LD [I],V1
+		0x12, 0x00, // 210: JP 0x200
+	})
+	if err := e.RunFrame(20); err != ErrHalted {
+		t.Fatalf("RunFrame() = %v, expected %v", err, ErrHalted)
+	}
+	if e.v[1] != 5 || e.v[2] != 5 || e.v[3] != 2 {
+		t.Errorf("V1-V3 = % x, expected 05 05 02", e.v[1:4])
+	}
+	if !e.interpret[ProgramAddress] || !e.interpret[ProgramAddress+1] {
+		t.Errorf("overwritten code at %#04x is still compiled", ProgramAddress)
+	}
+	if b := e.blocks[ProgramAddress+2]; b == nil || b.n != 2 {
+		t.Errorf("block at %#04x = %+v, expected 2 instructions", ProgramAddress+2, b)
+	}
+}
+
+func benchmarkRunFrame(b *testing.B, recompile bool) {
+	e := NewEmulator(QuirksCHIP48)
+	e.SetRecompiler(recompile)
+	e.Write(ProgramAddress, benchmarkROM)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.RunFrame(1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRunFrameInterpreted(b *testing.B) { benchmarkRunFrame(b, false) }
+func BenchmarkRunFrameRecompiled(b *testing.B)  { benchmarkRunFrame(b, true) }
@@ -0,0 +1,131 @@
+package emulator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ProgramAddress holds the address programs are loaded at by default.
+	ProgramAddress = 0x200
+
+	// ETI660ProgramAddress holds the address programs are loaded at on the
+	// ETI-660.
+	ETI660ProgramAddress = 0x600
+)
+
+// ErrROMTooLarge is returned when a ROM image does not fit in memory.
+var ErrROMTooLarge = errors.New("rom too large")
+
+// ROMInfo describes the ROM image loaded into the emulator.
+type ROMInfo struct {
+	Name string
+	Size int
+	SHA1 [sha1.Size]byte
+}
+
+// Hash returns the SHA-1 of the ROM image as a hex string.
+func (r ROMInfo) Hash() string {
+	return hex.EncodeToString(r.SHA1[:])
+}
+
+// SetStartAddress sets the address that programs are loaded at and that
+// execution starts from after a Reset. The default is ProgramAddress.
+func (e *Emulator) SetStartAddress(addr uint16) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.start = addr
+}
+
+// startAddress returns the address programs are loaded at.
+func (e *Emulator) startAddress() uint16 {
+	if e.start == 0 {
+		return ProgramAddress
+	}
+	return e.start
+}
+
+// Reset clears the registers, stack, timers, keypad and display, and restarts
+// execution at the start address. Memory is left untouched.
+func (e *Emulator) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.reset()
+}
+
+func (e *Emulator) reset() {
+	e.v = [Registers]byte{}
+	e.stack = [StackSize]uint16{}
+	e.sp = 0
+	e.i = 0
+	e.dt = 0
+	e.setSoundTimer(0)
+	e.keys = [Keys]bool{}
+	e.held = false
+	e.vblank = false
+	e.display = [HiresDisplayWidth * HiresDisplayHeight]byte{}
+	e.hires = false
+	e.markAllDirty()
+	e.flushDisplay()
+	e.plane = 1
+	e.pattern = defaultPattern
+	e.pitch = DefaultPitch
+	e.pc = e.startAddress()
+	e.err = nil
+}
+
+// LoadROM resets the emulator, clears program memory and loads the ROM image
+// read from r at the start address.
+func (e *Emulator) LoadROM(r io.Reader) error {
+	return e.loadROM("", r)
+}
+
+// LoadROMFile loads the ROM image stored in the named file. See LoadROM.
+func (e *Emulator) LoadROMFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return e.loadROM(filepath.Base(path), f)
+}
+
+func (e *Emulator) loadROM(name string, r io.Reader) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	start := e.startAddress()
+	max := e.memSize() - int(start)
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return fmt.Errorf("load rom: %w", err)
+	}
+	if len(b) > max {
+		return fmt.Errorf("load rom: image exceeds the %d bytes available at %#04x: %w", max, start, ErrROMTooLarge)
+	}
+	_, fontEnd := e.fontRange()
+	for a := int(fontEnd); a < len(e.mem); a++ {
+		e.mem[a] = 0
+	}
+	copy(e.mem[start:], b)
+	e.flushCache()
+	e.rom = ROMInfo{
+		Name: name,
+		Size: len(b),
+		SHA1: sha1.Sum(b),
+	}
+	e.reset()
+	return e.loadFlags()
+}
+
+// ROM returns information about the loaded ROM image.
+func (e *Emulator) ROM() ROMInfo {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rom
+}
@@ -0,0 +1,115 @@
+package emulator
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewEmulatorStartsAtProgramAddress(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	if e.pc != ProgramAddress {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, ProgramAddress)
+	}
+}
+
+func TestLoadROM(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	rom := []byte{0x60, 0x17, 0x12, 0x02}
+
+	e.v[3] = 0x42
+	e.sp = 2
+	e.Write(0x300, []byte{0xFF})
+	if err := e.LoadROM(bytes.NewReader(rom)); err != nil {
+		t.Fatalf("LoadROM() = %v, expected nil", err)
+	}
+
+	if b := e.Read(ProgramAddress, uint(len(rom))); !bytes.Equal(b, rom) {
+		t.Errorf("mem[%#04x] = % x, expected % x", ProgramAddress, b, rom)
+	}
+	if e.mem[0x300] != 0 {
+		t.Errorf("mem[%#04x] = %#02x, expected %#02x", 0x300, e.mem[0x300], 0)
+	}
+	if b := e.Read(FontAddress, uint(len(FontStandard.Small))); !bytes.Equal(b, FontStandard.Small[:]) {
+		t.Errorf("font was not preserved")
+	}
+	if e.pc != ProgramAddress || e.sp != 0 || e.v[3] != 0 {
+		t.Errorf("PC, SP, V3 = %#04x, %d, %#02x, expected reset state", e.pc, e.sp, e.v[3])
+	}
+	info := e.ROM()
+	if info.Size != len(rom) || info.SHA1 != sha1.Sum(rom) {
+		t.Errorf("ROM() = %+v, expected size %d and SHA-1 %x", info, len(rom), sha1.Sum(rom))
+	}
+}
+
+func TestLoadROMStartAddress(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.SetStartAddress(ETI660ProgramAddress)
+
+	if err := e.LoadROM(bytes.NewReader([]byte{0x12, 0x34})); err != nil {
+		t.Fatalf("LoadROM() = %v, expected nil", err)
+	}
+	if op, _ := e.ReadOpcode(ETI660ProgramAddress); op != 0x1234 {
+		t.Errorf("mem[%#04x] = %#04x, expected %#04x", ETI660ProgramAddress, op, 0x1234)
+	}
+	if e.pc != ETI660ProgramAddress {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, ETI660ProgramAddress)
+	}
+}
+
+func TestLoadROMTooLarge(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	err := e.LoadROM(bytes.NewReader(make([]byte, MemorySize-ProgramAddress+1)))
+	if !errors.Is(err, ErrROMTooLarge) {
+		t.Errorf("LoadROM() = %v, expected %v", err, ErrROMTooLarge)
+	}
+	if err := e.LoadROM(bytes.NewReader(make([]byte, MemorySize-ProgramAddress))); err != nil {
+		t.Errorf("LoadROM() = %v, expected nil", err)
+	}
+}
+
+func TestLoadROMFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.ch8")
+	if err := ioutil.WriteFile(path, []byte{0x00, 0xE0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEmulator(QuirksCHIP48)
+	if err := e.LoadROMFile(path); err != nil {
+		t.Fatalf("LoadROMFile() = %v, expected nil", err)
+	}
+	if info := e.ROM(); info.Name != "test.ch8" || info.Size != 2 {
+		t.Errorf("ROM() = %+v, expected name %q and size %d", info, "test.ch8", 2)
+	}
+	if err := e.LoadROMFile(filepath.Join(dir, "missing.ch8")); err == nil {
+		t.Errorf("LoadROMFile() = nil, expected an error")
+	}
+}
+
+// Test that Reset clears a halted program.
+func TestResetClearsHalt(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.WriteOpcode(0x00EE, ProgramAddress)
+
+	if _, err := e.Step(); err == nil {
+		t.Fatalf("Step() = nil, expected an error")
+	}
+	e.Reset()
+	if e.pc != ProgramAddress {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, ProgramAddress)
+	}
+	if _, err := e.Step(); err == nil {
+		t.Errorf("Step() = nil, expected the fault again")
+	}
+}
@@ -0,0 +1,143 @@
+package emulator
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultSpeed holds the default number of instructions executed per
+	// second by Run.
+	DefaultSpeed = 700
+)
+
+// ErrHalted is returned when the program has stopped, e.g. by jumping to
+// itself.
+var ErrHalted = errors.New("emulator halted")
+
+// SetSpeed sets the number of instructions per second executed by Run. A
+// value of zero or less selects DefaultSpeed.
+func (e *Emulator) SetSpeed(ips int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.speed = ips
+}
+
+// Seed seeds the random number generator used by RND, making its results
+// reproducible.
+func (e *Emulator) Seed(seed int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rnd = rand.New(rand.NewSource(seed))
+}
+
+// Step executes a single instruction and returns its opcode. Once the program
+// has halted or faulted, Step returns the same error without executing
+// anything further.
+func (e *Emulator) Step() (uint16, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.step()
+}
+
+func (e *Emulator) step() (uint16, error) {
+	opcode := e.opcodeAt(e.pc)
+	if e.err != nil {
+		return opcode, e.err
+	}
+	if int(e.pc)+1 >= e.memSize() {
+		// The fetch itself faulted, so the pc has not advanced.
+		f := e.fault(AddressOutOfRange, opcode)
+		f.PC = e.pc
+		e.err = f
+		return opcode, e.err
+	}
+	err := e.runCode()
+	e.flushDisplay()
+	if f, ok := err.(*Fault); ok {
+		err = e.handleFault(f)
+	}
+	e.err = err
+	return opcode, err
+}
+
+// RunFrame executes n instructions and then ticks the delay and sound timers
+// exactly once. It runs entirely on the calling goroutine, so a program driven
+// by RunFrame is fully reproducible. With the DisplayWait quirk the frame ends
+// early after a DRW.
+func (e *Emulator) RunFrame(n int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.runFrame(n)
+	return err
+}
+
+// RunFrameCount is like RunFrame but also returns the number of instructions
+// executed, which is less than n if the frame ended early after a DRW or the
+// program stopped.
+func (e *Emulator) RunFrameCount(n int) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.runFrame(n)
+}
+
+func (e *Emulator) runFrame(n int) (int, error) {
+	running := e.err == nil
+	e.pollKeyboard()
+	k := 0
+	for k < n && !e.vblank {
+		if b := e.block(); b != nil {
+			k += b.run(e, n-k)
+			continue
+		}
+		if _, err := e.step(); err != nil {
+			if running {
+				// Record the display as the program left it.
+				e.writeFrame()
+			}
+			return k, err
+		}
+		k++
+	}
+	e.vblank = false
+	err := e.playAudio()
+	if verr := e.writeFrame(); err == nil {
+		err = verr
+	}
+	e.tick()
+	return k, err
+}
+
+// Run executes the program at the configured speed, ticking the timers at
+// TimerFrequency, until ctx is cancelled or the program halts. It returns nil
+// if the program halted and ctx.Err() if it was cancelled. Other goroutines
+// may inspect the emulator while Run is executing.
+func (e *Emulator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(TimerFrequency)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		e.mu.Lock()
+		ips := e.speed
+		if ips <= 0 {
+			ips = DefaultSpeed
+		}
+		// Spread the instructions evenly over a second's worth of frames.
+		frames := TimerFrequencyHz
+		n := ips*(frame%frames+1)/frames - ips*(frame%frames)/frames
+		_, err := e.runFrame(n)
+		e.mu.Unlock()
+		if errors.Is(err, ErrHalted) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package emulator
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Test that RunFrame executes the requested number of instructions per frame
+// and ticks the timers once per frame.
+func TestRunFrame(t *testing.T) {
+	e := &Emulator{}
+
+	e.WriteOpcode(0x6003, 0x000) // LD V0,3
+	e.WriteOpcode(0xF015, 0x002) // LD DT,V0
+	e.WriteOpcode(0xF107, 0x004) // LD V1,DT
+	e.WriteOpcode(0x3100, 0x006) // SE V1,0
+	e.WriteOpcode(0x1004, 0x008) // JP 0x004
+	e.WriteOpcode(0x7201, 0x00A) // ADD V2,1
+
+	if err := e.RunFrame(2); err != nil {
+		t.Fatalf("RunFrame() = %v, expected nil", err)
+	}
+	if e.pc != 0x0004 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x0004)
+	}
+	if e.dt != 2 {
+		t.Errorf("DT = %#02x, expected %#02x", e.dt, 2)
+	}
+
+	frames := 1
+	for e.v[2] == 0 && frames < 10 {
+		if err := e.RunFrame(3); err != nil {
+			t.Fatalf("RunFrame() = %v, expected nil", err)
+		}
+		frames++
+	}
+	if frames != 4 {
+		t.Errorf("frames = %d, expected %d", frames, 4)
+	}
+}
+
+// Test that two emulators with the same seed produce the same random values.
+func TestSeed(t *testing.T) {
+	a := NewEmulator(QuirksCHIP48)
+	b := NewEmulator(QuirksCHIP48)
+	a.Seed(42)
+	b.Seed(42)
+	for i := uint16(0); i < 8; i++ {
+		a.WriteOpcode(0xC0FF|i<<8, ProgramAddress+2*i)
+		b.WriteOpcode(0xC0FF|i<<8, ProgramAddress+2*i)
+	}
+
+	a.RunFrame(8)
+	b.RunFrame(8)
+
+	if a.v != b.v {
+		t.Errorf("registers = % x, expected % x", a.v, b.v)
+	}
+}
+
+func TestStep(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	e.WriteOpcode(0x6017, 0x200) // LD V0,0x17
+	e.WriteOpcode(0x1202, 0x202) // JP 0x202
+
+	op, err := e.Step()
+	if op != 0x6017 || err != nil {
+		t.Errorf("Step() = %#04x, %v, expected %#04x, nil", op, err, 0x6017)
+	}
+	if e.v[0] != 0x17 {
+		t.Errorf("V0 = %#02x, expected %#02x", e.v[0], 0x17)
+	}
+
+	// a jump to itself halts the program
+	for i := 0; i < 2; i++ {
+		op, err = e.Step()
+		if op != 0x1202 || err != ErrHalted {
+			t.Errorf("Step() = %#04x, %v, expected %#04x, %v", op, err, 0x1202, ErrHalted)
+		}
+		if e.pc != 0x0202 {
+			t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x0202)
+		}
+	}
+}
+
+func TestRunHalts(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+	e.SetSpeed(6000)
+
+	e.WriteOpcode(0x7001, 0x200) // ADD V0,1
+	e.WriteOpcode(0x3050, 0x202) // SE V0,0x50
+	e.WriteOpcode(0x1200, 0x204) // JP 0x200
+	e.WriteOpcode(0x1206, 0x206) // JP 0x206
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.Run(ctx); err != nil {
+		t.Fatalf("Run() = %v, expected nil", err)
+	}
+	if e.v[0] != 0x50 {
+		t.Errorf("V0 = %#02x, expected %#02x", e.v[0], 0x50)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	e := NewEmulator(QuirksCHIP48)
+
+	e.WriteOpcode(0x7001, 0x200) // ADD V0,1
+	e.WriteOpcode(0x1200, 0x202) // JP 0x200
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*TimerFrequency)
+	defer cancel()
+	if err := e.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Run() = %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if _, err := e.Step(); err != nil {
+		t.Errorf("Step() = %v, expected nil", err)
+	}
+}
+
+// Test that RunFrameCount reports the instructions executed when the
+// DisplayWait quirk ends the frame at a DRW.
+func TestRunFrameCount(t *testing.T) {
+	e := NewEmulator(QuirksVIP)
+	for a := uint16(0); a < 8; a += 2 {
+		e.WriteOpcode(0xD011, ProgramAddress+a) // DRW V0,V1,1
+	}
+
+	n, err := e.RunFrameCount(11)
+	if err != nil {
+		t.Fatalf("RunFrameCount() = %v, expected nil", err)
+	}
+	if n != 1 || e.pc != ProgramAddress+2 {
+		t.Errorf("executed %d instructions to PC %#04x, expected 1 to %#04x", n, e.pc, ProgramAddress+2)
+	}
+}
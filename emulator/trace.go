@@ -0,0 +1,25 @@
+package emulator
+
+// Tracer observes the instructions executed by the emulator. Trace is called
+// with the address and decoded form of each instruction just before it is
+// executed. It runs on the goroutine executing the emulator and must not call
+// back into the emulator.
+type Tracer interface {
+	Trace(pc uint16, in Instruction)
+}
+
+// TracerFunc adapts a function to the Tracer interface.
+type TracerFunc func(pc uint16, in Instruction)
+
+// Trace calls fn(pc, in).
+func (fn TracerFunc) Trace(pc uint16, in Instruction) {
+	fn(pc, in)
+}
+
+// SetTracer sets the tracer that observes each instruction executed. Passing
+// nil disables tracing.
+func (e *Emulator) SetTracer(t Tracer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tracer = t
+}
@@ -0,0 +1,103 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// wavHeaderSize holds the size of the RIFF and format headers written before
+// the samples of a WAV file.
+const wavHeaderSize = 44
+
+// WAVSink is an AudioSink that writes the samples to a 16-bit mono WAV file.
+// The header is written with the final sizes by Close if the underlying
+// writer is an io.WriteSeeker; otherwise the sizes are left at their maximum
+// so the file can still be streamed.
+type WAVSink struct {
+	w          io.Writer
+	sampleRate int
+	size       int64
+	closed     bool
+}
+
+// NewWAVSink writes a WAV header for the given sample rate to w and returns a
+// sink that appends samples to it.
+func NewWAVSink(w io.Writer, sampleRate int) (*WAVSink, error) {
+	s := &WAVSink{w: w, sampleRate: sampleRate}
+	if err := s.writeHeader(0xFFFFFFFF - wavHeaderSize + 8); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *WAVSink) writeHeader(dataSize uint32) error {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+		blockAlign    = channels * bitsPerSample / 8
+	)
+	h := struct {
+		RIFF          [4]byte
+		ChunkSize     uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		Format        uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     dataSize + wavHeaderSize - 8,
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		Format:        1, // PCM
+		Channels:      channels,
+		SampleRate:    uint32(s.sampleRate),
+		ByteRate:      uint32(s.sampleRate * blockAlign),
+		BlockAlign:    blockAlign,
+		BitsPerSample: bitsPerSample,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      dataSize,
+	}
+	return binary.Write(s.w, binary.LittleEndian, &h)
+}
+
+// WriteSamples implements AudioSink.
+func (s *WAVSink) WriteSamples(samples []int16) error {
+	if s.closed {
+		return errors.New("wav: write to closed sink")
+	}
+	if err := binary.Write(s.w, binary.LittleEndian, samples); err != nil {
+		return err
+	}
+	s.size += int64(2 * len(samples))
+	return nil
+}
+
+// Close finishes the file, updating the header sizes if possible. It does not
+// close the underlying writer.
+func (s *WAVSink) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	ws, ok := s.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	if _, err := ws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.writeHeader(uint32(s.size)); err != nil {
+		return err
+	}
+	_, err := ws.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,65 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWAVSink(t *testing.T) {
+	f, err := ioutil.TempFile("", "chip8-*.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	sink, err := NewWAVSink(f, 8000)
+	if err != nil {
+		t.Fatalf("NewWAVSink() = %v, expected nil", err)
+	}
+	if err := sink.WriteSamples([]int16{1, -1, 256}); err != nil {
+		t.Fatalf("WriteSamples() = %v, expected nil", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() = %v, expected nil", err)
+	}
+	if err := sink.WriteSamples([]int16{1}); err == nil {
+		t.Errorf("WriteSamples() after Close() = nil, expected an error")
+	}
+
+	f.Seek(0, io.SeekStart)
+	b, _ := ioutil.ReadAll(f)
+	if len(b) != wavHeaderSize+6 {
+		t.Fatalf("file size = %d, expected %d", len(b), wavHeaderSize+6)
+	}
+	if string(b[0:4]) != "RIFF" || string(b[8:16]) != "WAVEfmt " || string(b[36:40]) != "data" {
+		t.Errorf("header = %q, expected a RIFF WAVE header", b[:wavHeaderSize])
+	}
+	le := binary.LittleEndian
+	if le.Uint32(b[4:]) != 42 || le.Uint32(b[40:]) != 6 {
+		t.Errorf("chunk sizes = %d, %d, expected 42, 6", le.Uint32(b[4:]), le.Uint32(b[40:]))
+	}
+	if le.Uint32(b[24:]) != 8000 || le.Uint16(b[34:]) != 16 || le.Uint16(b[22:]) != 1 {
+		t.Errorf("format = %d Hz, %d bits, %d channels, expected 8000 Hz, 16 bits, 1 channel", le.Uint32(b[24:]), le.Uint16(b[34:]), le.Uint16(b[22:]))
+	}
+	if !bytes.Equal(b[wavHeaderSize:], []byte{0x01, 0x00, 0xFF, 0xFF, 0x00, 0x01}) {
+		t.Errorf("samples = % x, expected 01 00 ff ff 00 01", b[wavHeaderSize:])
+	}
+}
+
+// Test that a WAV file can be streamed to a writer that cannot seek.
+func TestWAVSinkStream(t *testing.T) {
+	var buf bytes.Buffer
+	sink, _ := NewWAVSink(&buf, 8000)
+	sink.WriteSamples([]int16{1, 2})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() = %v, expected nil", err)
+	}
+	if buf.Len() != wavHeaderSize+4 {
+		t.Errorf("size = %d, expected %d", buf.Len(), wavHeaderSize+4)
+	}
+}
@@ -0,0 +1,145 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestXOCHIPMemory(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+
+	if err := e.LoadROM(bytes.NewReader(make([]byte, XOCHIPMemorySize-ProgramAddress))); err != nil {
+		t.Errorf("LoadROM() = %v, expected nil", err)
+	}
+	e.Write(0xF000, []byte{0x12, 0x34})
+	if b := e.Read(0xF000, 2); !bytes.Equal(b, []byte{0x12, 0x34}) {
+		t.Errorf("Read(0xF000) = % x, expected 12 34", b)
+	}
+}
+
+func TestLdILong(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	e.WriteOpcode(0xF000, 0x200)
+	e.WriteOpcode(0xE123, 0x202)
+
+	e.Step()
+
+	if e.i != 0xE123 {
+		t.Errorf("I = %#04x, expected %#04x", e.i, 0xE123)
+	}
+	if e.pc != 0x204 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x204)
+	}
+}
+
+// Test that skips step over both words of a long load.
+func TestSkipLongLoad(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	e.WriteOpcode(0x3000, 0x200) // SE V0,0
+	e.WriteOpcode(0xF000, 0x202)
+	e.WriteOpcode(0xE123, 0x204)
+
+	e.Step()
+
+	if e.pc != 0x206 {
+		t.Errorf("PC = %#04x, expected %#04x", e.pc, 0x206)
+	}
+}
+
+func TestRegisterRangeSaveLoad(t *testing.T) {
+	tests := []struct {
+		name   string
+		save   uint16
+		load   uint16
+		stored []byte
+	}{
+		{"ascending", 0x5242, 0x5243, []byte{2, 3, 4}},
+		{"descending", 0x5422, 0x5423, []byte{4, 3, 2}},
+		{"single", 0x5332, 0x5333, []byte{3, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEmulator(QuirksXOCHIP)
+			copy(e.v[:], []byte{0, 1, 2, 3, 4, 5})
+			e.i = 0x300
+			e.WriteOpcode(tt.save, 0x200)
+			e.WriteOpcode(tt.load, 0x202)
+
+			e.Step()
+			if b := e.Read(0x300, uint(len(tt.stored))); !bytes.Equal(b, tt.stored) {
+				t.Errorf("mem = % x, expected % x", b, tt.stored)
+			}
+			if e.i != 0x300 {
+				t.Errorf("I = %#04x, expected %#04x", e.i, 0x300)
+			}
+
+			saved := e.v
+			e.v = [Registers]byte{}
+			e.Step()
+			for r := 2; r <= 4; r++ {
+				if bytes.IndexByte(tt.stored, byte(r)) >= 0 && e.v[r] != saved[r] {
+					t.Errorf("V%1X = %#02x, expected %#02x", r, e.v[r], saved[r])
+				}
+			}
+		})
+	}
+}
+
+func TestPlaneDrawing(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	e.Write(0x300, []byte{0x80, 0xC0})
+	e.i = 0x300
+	e.WriteOpcode(0xF301, 0x200) // PLANE 3
+	e.WriteOpcode(0xD001, 0x202) // DRW V0,V0,1
+	e.WriteOpcode(0xF201, 0x204) // PLANE 2
+	e.WriteOpcode(0xD001, 0x206) // DRW V0,V0,1
+
+	e.Step()
+	e.Step()
+
+	f := e.Frame()
+	if f.At(0, 0) != 3 || f.At(1, 0) != 2 {
+		t.Errorf("pixels = %d %d, expected 3 2", f.At(0, 0), f.At(1, 0))
+	}
+	if e.v[0xF] != 0 {
+		t.Errorf("VF = %d, expected %d", e.v[0xF], 0)
+	}
+
+	// Only plane 2 is affected, and the collision is reported.
+	e.Step()
+	e.Step()
+	f = e.Frame()
+	if f.At(0, 0) != 1 || f.At(1, 0) != 2 {
+		t.Errorf("pixels = %d %d, expected 1 2", f.At(0, 0), f.At(1, 0))
+	}
+	if e.v[0xF] != 1 {
+		t.Errorf("VF = %d, expected %d", e.v[0xF], 1)
+	}
+}
+
+func TestPlaneClearAndScroll(t *testing.T) {
+	e := NewEmulator(QuirksXOCHIP)
+	e.display[0] = 3
+	e.display[1] = 1
+	e.WriteOpcode(0xF201, 0x200) // PLANE 2
+	e.WriteOpcode(0x00D1, 0x202) // SCU 1
+	e.WriteOpcode(0x00C1, 0x204) // SCD 1
+	e.WriteOpcode(0xF101, 0x206) // PLANE 1
+	e.WriteOpcode(0x00E0, 0x208) // CLS
+
+	e.Step()
+	e.Step()
+	if f := e.Frame(); f.At(0, 0) != 1 || f.At(1, 0) != 1 {
+		t.Errorf("after SCU pixels = %d %d, expected 1 1", f.At(0, 0), f.At(1, 0))
+	}
+	e.display[0] |= 2
+	e.Step()
+	if f := e.Frame(); f.At(0, 0) != 1 || f.At(0, 1) != 2 {
+		t.Errorf("after SCD pixels = %d %d, expected 1 2", f.At(0, 0), f.At(0, 1))
+	}
+	e.Step()
+	e.Step()
+	if f := e.Frame(); f.At(0, 0) != 0 || f.At(1, 0) != 0 || f.At(0, 1) != 2 {
+		t.Errorf("after CLS pixels = %d %d %d, expected 0 0 2", f.At(0, 0), f.At(1, 0), f.At(0, 1))
+	}
+}
@@ -0,0 +1,87 @@
+package emulator
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Y4MSink is a FrameSink that writes the frames it is given as an
+// uncompressed YUV4MPEG2 video at TimerFrequencyHz frames per second, one
+// video frame per emulator frame. Colours are converted to full-range YCbCr
+// with 4:2:0 chroma subsampling (C420jpeg), which most video tools accept.
+//
+// Every video frame has the size of the first frame, so a recording that
+// switches to the SUPER-CHIP high resolution mode keeps its size.
+type Y4MSink struct {
+	w       io.Writer
+	scale   int
+	palette color.Palette
+	ycbcr   []color.YCbCr
+	width   int
+	height  int
+	buf     []byte
+}
+
+// NewY4MSink returns a sink that writes video to w, scaling and colouring
+// frames as Frame.Image does. The stream header is written with the first
+// frame.
+func NewY4MSink(w io.Writer, scale int, p color.Palette) *Y4MSink {
+	if scale < 1 {
+		scale = 1
+	}
+	if len(p) == 0 {
+		p = DefaultPalette
+	}
+	s := &Y4MSink{w: w, scale: scale, palette: p}
+	for _, c := range p {
+		r, g, b, _ := c.RGBA()
+		y, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+		s.ycbcr = append(s.ycbcr, color.YCbCr{Y: y, Cb: cb, Cr: cr})
+	}
+	return s
+}
+
+// WriteFrame writes f as the next video frame.
+func (s *Y4MSink) WriteFrame(f Frame) error {
+	if s.width == 0 {
+		// The display dimensions are even, so the chroma planes are exactly
+		// half the size of the luma plane in each direction.
+		s.width, s.height = f.Width*s.scale, f.Height*s.scale
+		_, err := fmt.Fprintf(s.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg\n", s.width, s.height, TimerFrequencyHz)
+		if err != nil {
+			return err
+		}
+	}
+	img := f.resize(s.width, s.height, s.palette)
+
+	const header = "FRAME\n"
+	luma := s.width * s.height
+	chroma := luma / 4
+	if s.buf == nil {
+		s.buf = make([]byte, len(header)+luma+2*chroma)
+		copy(s.buf, header)
+	}
+	y := s.buf[len(header):]
+	cb := y[luma:]
+	cr := cb[chroma:]
+	for i, p := range img.Pix {
+		y[i] = s.ycbcr[p].Y
+	}
+	cw := s.width / 2
+	for row := 0; row < s.height/2; row++ {
+		for col := 0; col < cw; col++ {
+			// Average the chroma of each 2x2 block of pixels.
+			var sumCb, sumCr int
+			for _, off := range [4]int{0, 1, s.width, s.width + 1} {
+				c := s.ycbcr[img.Pix[row*2*s.width+col*2+off]]
+				sumCb += int(c.Cb)
+				sumCr += int(c.Cr)
+			}
+			cb[row*cw+col] = byte((sumCb + 2) / 4)
+			cr[row*cw+col] = byte((sumCr + 2) / 4)
+		}
+	}
+	_, err := s.w.Write(s.buf)
+	return err
+}
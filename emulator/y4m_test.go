@@ -0,0 +1,64 @@
+package emulator
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"testing"
+)
+
+func TestY4MSink(t *testing.T) {
+	var buf bytes.Buffer
+	p := color.Palette{color.Black, color.RGBA{0xFF, 0x00, 0x00, 0xFF}}
+	s := NewY4MSink(&buf, 2, p)
+
+	f := Frame{Width: DisplayWidth, Height: DisplayHeight, Pixels: make([]byte, DisplayWidth*DisplayHeight)}
+	f.Pixels[0] = 1
+	for n := 0; n < 2; n++ {
+		if err := s.WriteFrame(f); err != nil {
+			t.Fatalf("WriteFrame() = %v, expected nil", err)
+		}
+	}
+
+	header := fmt.Sprintf("YUV4MPEG2 W%d H%d F60:1 Ip A1:1 C420jpeg\n", DisplayWidth*2, DisplayHeight*2)
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte(header)) {
+		t.Fatalf("header = %q, expected %q", b[:len(header)], header)
+	}
+	b = b[len(header):]
+	luma := DisplayWidth * 2 * DisplayHeight * 2
+	size := len("FRAME\n") + luma + luma/2
+	if len(b) != 2*size {
+		t.Fatalf("frames = %d bytes, expected %d", len(b), 2*size)
+	}
+	if !bytes.Equal(b[:size], b[size:]) || string(b[:6]) != "FRAME\n" {
+		t.Errorf("frames differ or lack a FRAME header")
+	}
+
+	y := b[6:]
+	cb := y[luma:]
+	cr := cb[luma/4:]
+	ry, rcb, rcr := color.RGBToYCbCr(0xFF, 0x00, 0x00)
+	// The lit pixel covers the first 2x2 block, which is one chroma sample.
+	if y[0] != ry || y[1] != ry || y[DisplayWidth*2] != ry || y[2] != 0 {
+		t.Errorf("luma = % x, expected %02x for the lit pixel and 00 after", y[:3], ry)
+	}
+	if cb[0] != rcb || cr[0] != rcr || cb[1] != 128 || cr[1] != 128 {
+		t.Errorf("chroma = %02x %02x, %02x %02x, expected %02x %02x, 80 80", cb[0], cr[0], cb[1], cr[1], rcb, rcr)
+	}
+}
+
+// Test that the frame loop writes one video frame per emulator frame.
+func TestY4MFrameLoop(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEmulator(QuirksCHIP48)
+	e.SetFrameSink(NewY4MSink(&buf, 1, nil))
+	e.WriteOpcode(0x1202, 0x200) // JP 0x202
+	e.WriteOpcode(0x1200, 0x202) // JP 0x200
+	for n := 0; n < 5; n++ {
+		e.RunFrame(10)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("FRAME\n")); n != 5 {
+		t.Errorf("frames = %d, expected 5", n)
+	}
+}
@@ -0,0 +1,185 @@
+// Package term draws the CHIP-8 display on ANSI terminals and reads the
+// keypad from the keyboard, for running the emulator over SSH.
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// Mode selects the characters a Renderer draws pixels with.
+type Mode int
+
+const (
+	// HalfBlock draws two pixels, one above the other, in each cell using
+	// the upper half block character. Each pixel is drawn in its own colour.
+	HalfBlock Mode = iota
+
+	// Braille draws a block of 2x4 pixels in each cell using the braille
+	// patterns. Each cell is drawn in the colour of its brightest pixel.
+	Braille
+)
+
+// Modes holds the render modes, indexed by name.
+var Modes = map[string]Mode{
+	"halfblock": HalfBlock,
+	"braille":   Braille,
+}
+
+// Palette holds the 256-colour ANSI colour index of each pixel value. Pixel
+// values above 1 only occur in XO-CHIP programs that draw to both bitplanes.
+type Palette [4]uint8
+
+// DefaultPalette draws unlit pixels in black and lit pixels in white, with
+// orange and brown for the second XO-CHIP bitplane and the overlap of both.
+var DefaultPalette = Palette{16, 231, 208, 94}
+
+// cell holds the contents of one character cell.
+type cell struct {
+	r      rune
+	fg, bg uint8
+}
+
+// Renderer draws frames to a terminal using ANSI escapes. After the first
+// frame only the cells that have changed since the previous frame are
+// redrawn.
+type Renderer struct {
+	// Palette holds the colours pixels are drawn in.
+	Palette Palette
+
+	w     io.Writer
+	mode  Mode
+	scale int
+	buf   bytes.Buffer
+
+	cols, rows int
+	cells      []cell
+}
+
+// NewRenderer returns a renderer that draws to w, scaling each pixel by scale
+// in both directions before mapping pixels to cells.
+func NewRenderer(w io.Writer, mode Mode, scale int) *Renderer {
+	if scale < 1 {
+		scale = 1
+	}
+	return &Renderer{Palette: DefaultPalette, w: w, mode: mode, scale: scale}
+}
+
+// Size returns the number of columns and rows of cells the renderer needs to
+// draw a frame of the given resolution.
+func (r *Renderer) Size(width, height int) (cols, rows int) {
+	width *= r.scale
+	height *= r.scale
+	if r.mode == Braille {
+		return (width + 1) / 2, (height + 3) / 4
+	}
+	return width, (height + 1) / 2
+}
+
+// Rows returns the number of rows of cells drawn by the last call to Render.
+func (r *Renderer) Rows() int {
+	return r.rows
+}
+
+// Render draws f in the top left corner of the terminal. If the size of the
+// frame has changed since the last call the screen is cleared and redrawn.
+func (r *Renderer) Render(f emulator.Frame) error {
+	cols, rows := r.Size(f.Width, f.Height)
+	r.buf.Reset()
+	if cols != r.cols || rows != r.rows {
+		r.cols, r.rows = cols, rows
+		r.cells = make([]cell, cols*rows)
+		r.buf.WriteString("\x1b[0m\x1b[2J")
+	}
+
+	var pen cell
+	next := -1 // index of the cell at the cursor, or -1 if unknown
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			c := r.cell(f, col, row)
+			i := row*cols + col
+			if c == r.cells[i] {
+				continue
+			}
+			r.cells[i] = c
+			if i != next {
+				fmt.Fprintf(&r.buf, "\x1b[%d;%dH", row+1, col+1)
+			}
+			if c.fg != pen.fg || c.bg != pen.bg || pen.r == 0 {
+				fmt.Fprintf(&r.buf, "\x1b[38;5;%d;48;5;%dm", c.fg, c.bg)
+				pen = c
+			}
+			r.buf.WriteRune(c.r)
+			next = i + 1
+			if col == cols-1 {
+				// The cursor does not advance past the last column.
+				next = -1
+			}
+		}
+	}
+	if r.buf.Len() == 0 {
+		return nil
+	}
+	_, err := r.w.Write(r.buf.Bytes())
+	return err
+}
+
+// Invalidate forces the next call to Render to redraw the whole screen.
+func (r *Renderer) Invalidate() {
+	r.cols, r.rows = 0, 0
+}
+
+// pixel returns the value of the scaled pixel at (x, y), treating pixels
+// outside the frame as unlit.
+func (r *Renderer) pixel(f emulator.Frame, x, y int) byte {
+	x /= r.scale
+	y /= r.scale
+	if x >= f.Width || y >= f.Height {
+		return 0
+	}
+	return f.At(x, y) & 3
+}
+
+// cell returns the contents of the cell at (col, row).
+func (r *Renderer) cell(f emulator.Frame, col, row int) cell {
+	if r.mode == Braille {
+		return r.braille(f, col, row)
+	}
+	top := r.pixel(f, col, row*2)
+	bottom := r.pixel(f, col, row*2+1)
+	if top == bottom {
+		return cell{' ', r.Palette[top], r.Palette[bottom]}
+	}
+	return cell{'▀', r.Palette[top], r.Palette[bottom]}
+}
+
+// brailleDots holds the bit of each dot in a braille pattern, indexed by row
+// and column.
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+func (r *Renderer) braille(f emulator.Frame, col, row int) cell {
+	var dots rune
+	var max byte
+	for dy := 0; dy < 4; dy++ {
+		for dx := 0; dx < 2; dx++ {
+			if p := r.pixel(f, col*2+dx, row*4+dy); p != 0 {
+				dots |= brailleDots[dy][dx]
+				if p > max {
+					max = p
+				}
+			}
+		}
+	}
+	if dots == 0 {
+		return cell{' ', r.Palette[1], r.Palette[0]}
+	}
+	return cell{0x2800 + dots, r.Palette[max], r.Palette[0]}
+}
@@ -0,0 +1,113 @@
+package term
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+func newFrame(w, h int) emulator.Frame {
+	return emulator.Frame{Width: w, Height: h, Pixels: make([]byte, w*h)}
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		mode       Mode
+		scale      int
+		w, h       int
+		cols, rows int
+	}{
+		{HalfBlock, 1, 64, 32, 64, 16},
+		{HalfBlock, 1, 128, 64, 128, 32},
+		{HalfBlock, 2, 64, 32, 128, 32},
+		{Braille, 1, 64, 32, 32, 8},
+		{Braille, 1, 128, 64, 64, 16},
+		{Braille, 3, 64, 32, 96, 24},
+	}
+	for _, tt := range tests {
+		r := NewRenderer(nil, tt.mode, tt.scale)
+		cols, rows := r.Size(tt.w, tt.h)
+		if cols != tt.cols || rows != tt.rows {
+			t.Errorf("Size(%d, %d) in mode %d scale %d = %d, %d, expected %d, %d",
+				tt.w, tt.h, tt.mode, tt.scale, cols, rows, tt.cols, tt.rows)
+		}
+	}
+}
+
+func TestHalfBlockCells(t *testing.T) {
+	r := NewRenderer(nil, HalfBlock, 1)
+	f := newFrame(2, 2)
+	f.Pixels[0] = 1 // top left
+	f.Pixels[3] = 2 // bottom right
+
+	p := DefaultPalette
+	if c, exp := r.cell(f, 0, 0), (cell{'▀', p[1], p[0]}); c != exp {
+		t.Errorf("cell(0, 0) = %v, expected %v", c, exp)
+	}
+	if c, exp := r.cell(f, 1, 0), (cell{'▀', p[0], p[2]}); c != exp {
+		t.Errorf("cell(1, 0) = %v, expected %v", c, exp)
+	}
+}
+
+func TestBrailleCells(t *testing.T) {
+	r := NewRenderer(nil, Braille, 1)
+	f := newFrame(4, 4)
+	f.Pixels[0] = 1  // (0, 0): dot 1
+	f.Pixels[13] = 1 // (1, 3): dot 8
+	f.Pixels[7] = 3  // (3, 1): dot 5
+
+	p := DefaultPalette
+	if c, exp := r.cell(f, 0, 0), (cell{'⢁', p[1], p[0]}); c != exp {
+		t.Errorf("cell(0, 0) = %q, expected %q", c.r, exp.r)
+	}
+	if c, exp := r.cell(f, 1, 0), (cell{'⠐', p[3], p[0]}); c != exp {
+		t.Errorf("cell(1, 0) = %v, expected %v", c, exp)
+	}
+}
+
+// Test that only changed cells are redrawn after the first frame.
+func TestRenderChangedCells(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out, HalfBlock, 1)
+	f := newFrame(emulator.DisplayWidth, emulator.DisplayHeight)
+
+	if err := r.Render(f); err != nil {
+		t.Fatal(err)
+	}
+	if s := out.String(); !strings.HasPrefix(s, "\x1b[0m\x1b[2J") || strings.Count(s, " ") != 64*16 {
+		t.Errorf("first frame did not redraw the whole screen: %q", s)
+	}
+
+	out.Reset()
+	r.Render(f)
+	if out.Len() != 0 {
+		t.Errorf("unchanged frame wrote %q, expected nothing", out.String())
+	}
+
+	f.Pixels[5*emulator.DisplayWidth+10] = 1 // row 2, column 10
+	f.Pixels[5*emulator.DisplayWidth+11] = 1
+	out.Reset()
+	r.Render(f)
+	exp := "\x1b[3;11H\x1b[38;5;16;48;5;231m▀▀"
+	if out.String() != exp {
+		t.Errorf("changed frame wrote %q, expected %q", out.String(), exp)
+	}
+}
+
+// Test that a change of resolution clears and redraws the screen.
+func TestRenderResize(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out, Braille, 1)
+	r.Render(newFrame(emulator.DisplayWidth, emulator.DisplayHeight))
+
+	out.Reset()
+	r.Render(newFrame(emulator.HiresDisplayWidth, emulator.HiresDisplayHeight))
+	if s := out.String(); !strings.HasPrefix(s, "\x1b[0m\x1b[2J") || strings.Count(s, " ") != 64*16 {
+		t.Errorf("resized frame did not redraw the whole screen: %q", s)
+	}
+	if r.Rows() != 16 {
+		t.Errorf("Rows() = %d, expected 16", r.Rows())
+	}
+}
@@ -0,0 +1,146 @@
+package term
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/markcol/chip8-go/emulator"
+)
+
+// DefaultKeymap maps the left side of a QWERTY keyboard onto the keypad,
+// listed in key order 0-F:
+//
+//	1 2 3 C      1 2 3 4
+//	4 5 6 D  =>  q w e r
+//	7 8 9 E      a s d f
+//	A 0 B F      z x c v
+const DefaultKeymap = "x123qweasdzc4rfv"
+
+// holdFrames holds the number of frames a key stays down after it is typed.
+// Terminals do not report key releases, so presses are held for a short time
+// and kept alive by the keyboard's auto-repeat.
+const holdFrames = 6
+
+// Terminal draws the display on a terminal in raw mode and reads the keypad
+// from its keyboard. It implements both emulator.Display and
+// emulator.Keyboard.
+type Terminal struct {
+	in     *os.File
+	out    *bufio.Writer
+	r      *Renderer
+	keymap map[byte]byte
+	saved  string
+
+	mu      sync.Mutex
+	hold    [emulator.Keys]int
+	frame   emulator.Frame
+	changed bool
+}
+
+// Open puts the terminal connected to in and out into raw mode and returns a
+// Terminal that draws in the given mode and scale. keymap lists the keys
+// typed for keypad keys 0-F, as in DefaultKeymap.
+func Open(in, out *os.File, mode Mode, scale int, keymap string) (*Terminal, error) {
+	if len(keymap) != emulator.Keys {
+		return nil, fmt.Errorf("term: keymap must have %d keys, got %d", emulator.Keys, len(keymap))
+	}
+	t := &Terminal{
+		in:     in,
+		out:    bufio.NewWriter(out),
+		keymap: make(map[byte]byte),
+	}
+	t.r = NewRenderer(t.out, mode, scale)
+	for k := 0; k < len(keymap); k++ {
+		t.keymap[keymap[k]] = byte(k)
+	}
+	saved, err := t.stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("term: %v", err)
+	}
+	t.saved = strings.TrimSpace(saved)
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("term: %v", err)
+	}
+	// Hide the cursor; the renderer clears the screen on the first frame.
+	fmt.Fprint(t.out, "\x1b[?25l")
+	return t, nil
+}
+
+// stty runs stty(1) against the terminal.
+func (t *Terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.in
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Close restores the terminal to the state it was in before Open, leaving the
+// cursor below the display.
+func (t *Terminal) Close() error {
+	fmt.Fprintf(t.out, "\x1b[0m\x1b[%d;1H\x1b[?25h\r\n", t.r.Rows())
+	if err := t.out.Flush(); err != nil {
+		return err
+	}
+	_, err := t.stty(t.saved)
+	return err
+}
+
+// ReadKeys reads key presses until the user types Escape or Ctrl-C, or input
+// is closed.
+func (t *Terminal) ReadKeys() {
+	r := bufio.NewReader(t.in)
+	for {
+		c, err := r.ReadByte()
+		if err != nil || c == 0x1B || c == 0x03 {
+			return
+		}
+		if k, ok := t.keymap[c]; ok {
+			t.mu.Lock()
+			t.hold[k] = holdFrames
+			t.mu.Unlock()
+		}
+	}
+}
+
+// Poll implements emulator.Keyboard.
+func (t *Terminal) Poll() [emulator.Keys]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var keys [emulator.Keys]bool
+	for k := range t.hold {
+		if t.hold[k] > 0 {
+			keys[k] = true
+			t.hold[k]--
+		}
+	}
+	return keys
+}
+
+// Update implements emulator.Display. The frame is drawn by the next call to
+// Refresh.
+func (t *Terminal) Update(f emulator.Frame, dirty image.Rectangle) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.frame = f
+	t.changed = true
+}
+
+// Refresh draws the cells that have changed since the last refresh.
+func (t *Terminal) Refresh() error {
+	t.mu.Lock()
+	f, changed := t.frame, t.changed
+	t.changed = false
+	t.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	if err := t.r.Render(f); err != nil {
+		return err
+	}
+	return t.out.Flush()
+}